@@ -10,41 +10,42 @@
 package ahocorasick
 
 import (
-	"container/list"
 	"sync"
 	"sync/atomic"
+	"unicode/utf8"
 )
 
 // A node in the trie structure used to implement Aho-Corasick
 type node struct {
 	root bool // true if this is the root
 
-	b []byte // The blice at this node
-
 	output bool // True means this node represents a blice that should
 	// be output when matching
 	index int // index into original dictionary if output is true
 
-	counter uint64 // Set to the value of the Matcher.counter when a
-	// match is output to prevent duplicate output
-	// The use of fixed size arrays is space-inefficient but fast for
-	// lookups.
+	id uint32 // The offset of this node in the trie, which is its
+	// state number when matching
+
+	// These slices have an entry for each byte class (see
+	// Matcher.classes) rather than for each byte, which is fast for
+	// lookups while using much less space than a fixed size array.
 
-	child [256]*node // A non-nil entry in this array means that the
-	// index represents a byte value which can be
+	child []*node // A non-nil entry in this slice means that the
+	// bytes in the class it is indexed by can be
 	// appended to the current node. Blices in the
 	// trie are built up byte by byte through these
 	// child node pointers.
 
-	fails [256]*node // Where to fail to (by following the fail
-	// pointers) for each possible byte
+	fails []*node // Where to fail to (by following the fail
+	// pointers) for each byte class
 
-	suffix *node // Pointer to the longest possible strict suffix of
-	// this node
+	suffix *node // Pointer to the next node which is in the dictionary
+	// which can be reached from here following fail pointers, or the
+	// root if there is none
 
-	fail *node // Pointer to the next node which is in the dictionary
-	// which can be reached from here following suffixes. Called fail
-	// because it is used to fallback in the trie when a match fails.
+	fail *node // Pointer to the longest possible strict suffix of
+	// this node which is in the trie. Called fail because it is used
+	// to fallback in the trie when a match fails.
 }
 
 // Matcher is returned by NewMatcher and contains a list of blices to
@@ -52,27 +53,184 @@ type node struct {
 type Matcher struct {
 	counter uint64 // Counts the number of matches done, and is used to
 	// prevent output of multiple matches of the same string
+	seen []uint64 // Set to the value of counter when the dictionary
+	// entry with the same index is output by Match
 	trie []node // preallocated block of memory containing all the
 	// nodes
 	extent int   // offset into trie that is currently free
 	root   *node // Points to trie[0]
 
+	classes [256]byte // The class of each byte. Bytes which are in
+	// the same class lead to the same transitions everywhere in the
+	// trie, so the nodes only need an entry per class.
+	stride int        // The number of classes
+	starts [256]*node // The child of the root for each byte, or nil
+	edges  []*node    // Block of memory that the child and fails
+	// slices of nodes are taken from
+
+	sparse *compact // The automaton used instead of the trie when
+	// the Matcher was created WithCompact()
+
+	table *dfa // The automaton used instead of the trie when the
+	// Matcher was created WithDFA()
+
+	lens []int // Length of each dictionary entry, after folding
+
+	dups []int // The index of the next dictionary entry which is
+	// the same as each one after folding, or -1 if there is none. The
+	// automaton only outputs the first of a set of duplicates. nil if
+	// the dictionary has no duplicates.
+
 	heap sync.Pool // a pool of haystacks to de-duplicate results in
 	// a thread-safe manner
+
+	all bool // true if every occurrence is reported rather than
+	// each dictionary entry once per call
+
+	fold bool // true if ASCII letters match regardless of case
+
+	compact bool // true if the compact automaton is used
+
+	dfa bool // true if the trie is compiled into a dfa
+
+	unicode bool // true if runes are folded using Unicode simple
+	// case folding before matching
+
+	isWord func(rune) bool // Decides which runes are part of words
+	// when only whole words are matched, or nil
+
+	longest int // length of the longest dictionary entry, after
+	// folding
+
+	kind MatchKind // how FindAllNonOverlapping chooses between
+	// overlapping occurrences
+
+	// A Matcher built by Builder.Build from the changes made since
+	// base was built uses the automaton of base rather than one of
+	// its own
+
+	base    *Matcher // The Matcher whose automaton is used, or nil
+	delta   *Matcher // The entries added since base was built, or nil
+	added   []int    // The index of each entry of delta
+	removed []bool   // true for each entry of base removed since
 }
 
-// findBlice looks for a blice in the trie starting from the root and
-// returns a pointer to the node representing the end of the blice. If
-// the blice is not found it returns nil.
-func (m *Matcher) findBlice(b []byte) *node {
-	n := &m.trie[0]
+// walk runs in through the Matcher's automaton starting at state s and
+// calls fn with the index of every dictionary entry found, along with
+// the offset just past its end. Walking stops early if fn returns
+// false. The state reached at the end of the walk is returned. It is
+// wider than the state of an automaton as a Matcher built by a Builder
+// may walk two of them (see walkLayered).
+//
+// Duplicate entries are found together, in the order of their indexes.
+func (m *Matcher) walk(in []byte, s uint64, fn func(index, end int) bool) uint64 {
+	if m.base != nil {
+		return m.walkLayered(in, s, fn)
+	}
+
+	return uint64(m.walkEntries(in, uint32(s), fn))
+}
 
-	for n != nil && len(b) > 0 {
-		n = n.child[int(b[0])]
-		b = b[1:]
+// walkEntries does the work of walk() for a Matcher with an automaton
+// of its own, finding all of a set of duplicate entries where the
+// automaton only outputs the first.
+func (m *Matcher) walkEntries(in []byte, s uint32, fn func(index, end int) bool) uint32 {
+	if m.dups == nil {
+		return m.walkAutomaton(in, s, fn)
 	}
 
-	return n
+	return m.walkAutomaton(in, s, func(index, end int) bool {
+		for ; index >= 0; index = m.dups[index] {
+			if !fn(index, end) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// walkAutomaton does the work of walkEntries() for whichever automaton
+// the Matcher has.
+//
+// States are numbered, with the root being state 0. Each of the ways a
+// Matcher can represent its automaton has a walk method of its own;
+// they are called directly rather than through an interface so that
+// fn does not escape to the heap.
+func (m *Matcher) walkAutomaton(in []byte, s uint32, fn func(index, end int) bool) uint32 {
+	if m.table != nil {
+		return m.table.walk(in, s, fn)
+	}
+
+	if m.sparse != nil {
+		return m.sparse.walk(in, s, fn)
+	}
+
+	return m.walkTrie(in, s, fn)
+}
+
+// walkTrie walks the default automaton, which runs on the nodes of the
+// trie directly. Every node has a transition for every byte class.
+//
+// Most input leaves the automaton at the root, so the root's children
+// are looked up by byte in m.starts rather than through the classes,
+// which keeps bytes that match nothing as cheap as they were when
+// every node had a transition for every byte.
+func (m *Matcher) walkTrie(in []byte, s uint32, fn func(index, end int) bool) uint32 {
+	n := &m.trie[s]
+	classes, starts := &m.classes, &m.starts
+
+	for i, b := range in {
+		var f *node
+
+		if n.root {
+			if f = starts[b]; f == nil {
+				continue
+			}
+		} else {
+			c := classes[b]
+
+			// A node without a child for c fails to a node which
+			// has one, or to the root
+
+			if f = n.child[c]; f == nil {
+				n = n.fails[c]
+				if f = n.child[c]; f == nil {
+					continue
+				}
+			}
+		}
+
+		n = f
+
+		if f.output {
+			if !fn(f.index, i+1) {
+				return n.id
+			}
+		}
+
+		for !f.suffix.root {
+			f = f.suffix
+			if !fn(f.index, i+1) {
+				return n.id
+			}
+		}
+	}
+
+	return n.id
+}
+
+// An Option configures a Matcher when it is created by NewMatcher or
+// NewStringMatcher
+type Option func(*Matcher)
+
+// WithAllOccurrences makes Match and MatchThreadSafe return an index
+// for every occurrence of a dictionary entry in the input, including
+// repeated and overlapping ones, rather than each index at most once.
+// This is useful for counting how often each entry appears.
+func WithAllOccurrences() Option {
+	return func(m *Matcher) {
+		m.all = true
+	}
 }
 
 // getFreeNode: gets a free node structure from the Matcher's trie
@@ -85,7 +243,13 @@ func (m *Matcher) getFreeNode() *node {
 		m.root.root = true
 	}
 
-	return &m.trie[m.extent-1]
+	n := &m.trie[m.extent-1]
+	n.id = uint32(m.extent - 1)
+	n.child = m.edges[:m.stride:m.stride]
+	n.fails = m.edges[m.stride : 2*m.stride : 2*m.stride]
+	m.edges = m.edges[2*m.stride:]
+
+	return n
 }
 
 // buildTrie builds the fundamental trie structure from a set of
@@ -102,6 +266,9 @@ func (m *Matcher) buildTrie(dictionary [][]byte) {
 	}
 	m.trie = make([]node, max)
 
+	m.buildClasses(dictionary)
+	m.edges = make([]*node, 2*m.stride*max)
+
 	// Calling this an ignoring its argument simply allocated
 	// m.trie[0] which will be the root element
 
@@ -112,109 +279,263 @@ func (m *Matcher) buildTrie(dictionary [][]byte) {
 
 	for i, blice := range dictionary {
 		n := m.root
-		var path []byte
 		for _, b := range blice {
-			path = append(path, b)
-
-			c := n.child[int(b)]
+			c := n.child[m.classes[b]]
 
 			if c == nil {
 				c = m.getFreeNode()
-				n.child[int(b)] = c
-				c.b = make([]byte, len(path))
-				copy(c.b, path)
-
-				// Nodes directly under the root node will have the
-				// root as their fail point as there are no suffixes
-				// possible.
-
-				if len(path) == 1 {
-					c.fail = m.root
-				}
-
-				c.suffix = m.root
+				n.child[m.classes[b]] = c
 			}
 
 			n = c
 		}
 
 		// The last value of n points to the node representing a
-		// dictionary entry
+		// dictionary entry. An empty entry would be the root, which
+		// is never output.
+
+		if n.root {
+			continue
+		}
+
+		if n.output {
+			m.duplicate(n.index, i)
+			continue
+		}
 
 		n.output = true
 		n.index = i
 	}
 
-	l := new(list.List)
-	l.PushBack(m.root)
+	// The fail and suffix pointers are worked out breadth first, so
+	// that those of every shorter node are already known. The fail
+	// pointer of a child is found by following the fail pointers of
+	// its parent until a node with a child for the same byte class is
+	// reached, as a suffix of the child must be a suffix of the parent
+	// followed by that byte. Nodes directly under the root have the
+	// root as their fail pointer as no suffixes are possible.
 
-	for l.Len() > 0 {
-		n := l.Remove(l.Front()).(*node)
+	queue := make([]*node, 1, m.extent)
+	queue[0] = m.root
 
-		for i := 0; i < 256; i++ {
-			c := n.child[i]
-			if c != nil {
-				l.PushBack(c)
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
 
-				for j := 1; j < len(c.b); j++ {
-					c.fail = m.findBlice(c.b[j:])
-					if c.fail != nil {
-						break
-					}
-				}
+		for k, c := range n.child {
+			if c == nil {
+				continue
+			}
+			queue = append(queue, c)
 
-				if c.fail == nil {
-					c.fail = m.root
+			c.fail = m.root
+			if !n.root {
+				f := n.fail
+				for f.child[k] == nil && !f.root {
+					f = f.fail
 				}
-
-				for j := 1; j < len(c.b); j++ {
-					s := m.findBlice(c.b[j:])
-					if s != nil && s.output {
-						c.suffix = s
-						break
-					}
+				if f.child[k] != nil {
+					c.fail = f.child[k]
 				}
 			}
+
+			if c.fail.output {
+				c.suffix = c.fail
+			} else if c.fail.root {
+				c.suffix = m.root
+			} else {
+				c.suffix = c.fail.suffix
+			}
 		}
 	}
 
-	for i := 0; i < m.extent; i++ {
-		for c := 0; c < 256; c++ {
-			n := &m.trie[i]
-			for n.child[c] == nil && !n.root {
-				n = n.fail
+	m.trie = m.trie[:m.extent]
+	m.buildFails()
+	m.edges = nil
+}
+
+// buildFails fills in the fails slices of the nodes in the trie once
+// their fail pointers are known, along with m.starts. The nodes are
+// visited breadth first so that the fails slice of a node's fail
+// pointer, which is shorter, is always known and can be used rather
+// than following fail pointers all the way.
+func (m *Matcher) buildFails() {
+	for b := range m.starts {
+		m.starts[b] = m.root.child[m.classes[b]]
+	}
+
+	queue := make([]*node, 1, len(m.trie))
+	queue[0] = m.root
+
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+
+		for c, f := range n.child {
+			if f != nil {
+				queue = append(queue, f)
 			}
 
-			m.trie[i].fails[c] = n
+			if f != nil || n.root {
+				n.fails[c] = n
+			} else {
+				n.fails[c] = n.fail.fails[c]
+			}
 		}
 	}
+}
 
-	m.trie = m.trie[:m.extent]
+// duplicate records that dictionary entry i is the same as entry
+// first, which is already in the automaton
+func (m *Matcher) duplicate(first, i int) {
+	if m.dups == nil {
+		m.dups = make([]int, len(m.lens))
+		for j := range m.dups {
+			m.dups[j] = -1
+		}
+	}
+
+	for m.dups[first] >= 0 {
+		first = m.dups[first]
+	}
+	m.dups[first] = i
+}
+
+// buildClasses works out the byte classes for a dictionary. Each byte
+// that appears in the dictionary needs a class of its own, but all the
+// others can share one as they never lead anywhere but the root.
+func (m *Matcher) buildClasses(dictionary [][]byte) {
+	var used [256]bool
+	for _, blice := range dictionary {
+		for _, b := range blice {
+			used[b] = true
+		}
+	}
+
+	// Class 0 is for the bytes that are not used, if there are any
+
+	m.stride = 0
+	for _, u := range used {
+		if !u {
+			m.stride = 1
+			break
+		}
+	}
+
+	for b, u := range used {
+		if u {
+			m.classes[b] = byte(m.stride)
+			m.stride++
+		}
+	}
+
+	// When folding case the dictionary only contains lower case
+	// letters, so the upper case ones are put in the same classes
+
+	if m.fold {
+		for b := 'A'; b <= 'Z'; b++ {
+			m.classes[b] = m.classes[b+'a'-'A']
+		}
+	}
+}
+
+// WithCaseInsensitive makes the Matcher ignore the case of ASCII
+// letters, both in the dictionary and in the input. The case is folded
+// when the trie is built so matching costs the same as without it.
+func WithCaseInsensitive() Option {
+	return func(m *Matcher) {
+		m.fold = true
+	}
+}
+
+// lower returns the lower case version of an ASCII letter and leaves
+// any other byte untouched
+func lower(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+
+	return b
+}
+
+// prepare returns the dictionary as it is put into the trie, folding
+// case if the Matcher was asked to, and records the length of each
+// entry
+func (m *Matcher) prepare(dictionary [][]byte) [][]byte {
+	d := make([][]byte, len(dictionary))
+	m.lens = make([]int, len(dictionary))
+
+	for i, blice := range dictionary {
+		if m.unicode {
+			blice = foldBlice(blice)
+		}
+
+		if m.fold {
+			l := make([]byte, len(blice))
+			for j, b := range blice {
+				l[j] = lower(b)
+			}
+			blice = l
+		}
+
+		d[i] = blice
+		m.lens[i] = len(blice)
+		if len(blice) > m.longest {
+			m.longest = len(blice)
+		}
+	}
+
+	return d
 }
 
 // NewMatcher creates a new Matcher used to match against a set of
-// blices
-func NewMatcher(dictionary [][]byte) *Matcher {
+// blices. A blice which appears in the dictionary more than once, which
+// includes differing only in case when case is being ignored, is found
+// under each of its indexes.
+func NewMatcher(dictionary [][]byte, opts ...Option) *Matcher {
 	m := new(Matcher)
 
-	m.buildTrie(dictionary)
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	d := m.prepare(dictionary)
+	m.seen = make([]uint64, len(d))
+
+	if m.compact {
+		m.sparse = newCompact(m, d)
+	} else {
+		m.buildTrie(d)
+	}
+
+	// A trie too large for the dfa to number its states is used as
+	// it is
+
+	if m.dfa && !dfaFits(len(m.trie), m.stride) {
+		m.dfa = false
+	}
+
+	// The trie is only needed to build the dfa
+
+	if m.dfa {
+		m.table = newDFA(m)
+		m.trie = nil
+		m.root = nil
+		m.starts = [256]*node{}
+	}
 
 	return m
 }
 
 // NewStringMatcher creates a new Matcher used to match against a set
 // of strings (this is a helper to make initialization easy)
-func NewStringMatcher(dictionary []string) *Matcher {
-	m := new(Matcher)
-
+func NewStringMatcher(dictionary []string, opts ...Option) *Matcher {
 	var d [][]byte
 	for _, s := range dictionary {
 		d = append(d, []byte(s))
 	}
 
-	m.buildTrie(d)
-
-	return m
+	return NewMatcher(d, opts...)
 }
 
 // Match searches in for blices and returns all the blices found as indexes into
@@ -222,54 +543,124 @@ func NewStringMatcher(dictionary []string) *Matcher {
 //
 // This is not thread-safe method, seek for MatchThreadSafe() instead.
 func (m *Matcher) Match(in []byte) []int {
+	if m.all {
+		return m.match(in, every)
+	}
+
 	m.counter++
 
-	return match(in, m.root, func(f *node) bool {
-		if f.counter != m.counter {
-			f.counter = m.counter
+	return m.match(in, func(i int) bool {
+		if m.seen[i] != m.counter {
+			m.seen[i] = m.counter
 			return true
 		}
 		return false
 	})
 }
 
-// match is a core of matching logic. Accepts input byte slice, starting node
-// and a func to check whether should we include result into response or not
-func match(in []byte, n *node, unique func(f *node) bool) []int {
-	var hits []int
+// Match records a single occurrence of a dictionary entry in the input
+type Match struct {
+	Pattern int // index into the original dictionary
+	Start   int // offset of the first byte of the occurrence
+	End     int // offset just past the last byte of the occurrence
+}
+
+// FindAll searches in for blices and returns every occurrence found,
+// including repeated and overlapping ones, in the order in which they
+// end. Occurrences ending at the same offset are ordered longest
+// first.
+//
+// Unlike Match() this is thread-safe as no state is kept between calls.
+func (m *Matcher) FindAll(in []byte) []Match {
+	return m.findAll(nil, in)
+}
 
-	for _, b := range in {
-		c := int(b)
+// findAll does the work of FindAll(). before is the input which came
+// before `in`, which is only used to check word boundaries.
+func (m *Matcher) findAll(before, in []byte) []Match {
+	var hits []Match
 
-		if !n.root && n.child[c] == nil {
-			n = n.fails[c]
+	m.scanAfter(before, in, func(i, end int) bool {
+		if hits == nil {
+			hits = make([]Match, 0, 4) // See match()
 		}
+		hits = append(hits, Match{i, m.start(in, i, end), end})
+		return true
+	})
+
+	return hits
+}
 
-		if n.child[c] != nil {
-			f := n.child[c]
-			n = f
+// scan runs in through the automaton from the root, calling fn as
+// walk() does. It takes care of folding in if the Matcher was created
+// WithUnicodeFolding() and of skipping occurrences which are not at
+// word boundaries if it was created WithWordBoundaries().
+func (m *Matcher) scan(in []byte, fn func(index, end int) bool) {
+	m.scanAfter(nil, in, fn)
+}
 
-			if f.output {
-				if unique(f) {
-					hits = append(hits, f.index)
-				}
+// scanAfter is scan() for input which follows before
+func (m *Matcher) scanAfter(before, in []byte, fn func(index, end int) bool) {
+	if m.isWord != nil {
+		found := fn
+		fn = func(i, end int) bool {
+			if !m.atBoundaries(before, in, m.start(in, i, end), end) {
+				return true
 			}
+			return found(i, end)
+		}
+	}
 
-			for !f.suffix.root {
-				f = f.suffix
-				if unique(f) {
-					hits = append(hits, f.index)
-				} else {
+	if m.unicode {
+		m.walkFolded(in, 0, 0, false, fn)
+	} else {
+		m.walk(in, 0, fn)
+	}
+}
 
-					// There's no point working our way up the
-					// suffixes if it's been done before for this call
-					// to Match. The matches are already in hits.
+// start returns the offset in `in` at which the dictionary entry with
+// the given index, found ending at end, starts
+func (m *Matcher) start(in []byte, index, end int) int {
+	if m.unicode {
+		return foldedStart(in, end, m.lens[index])
+	}
 
-					break
-				}
+	return end - m.lens[index]
+}
+
+// span returns the largest number of bytes of input that an
+// occurrence of a dictionary entry can cover
+func (m *Matcher) span() int {
+	if m.unicode {
+		return m.longest * utf8.UTFMax
+	}
+
+	return m.longest
+}
+
+// every is used in place of a uniqueness check when every occurrence
+// is wanted in the result
+func every(int) bool {
+	return true
+}
+
+// match is a core of matching logic. Accepts input byte slice and a
+// func to check whether should we include result into response or not
+func (m *Matcher) match(in []byte, unique func(index int) bool) []int {
+	var hits []int
+
+	// Appending inside the closure always goes to the heap, so room
+	// for a few hits is made at once rather than growing from one
+
+	m.scan(in, func(i, _ int) bool {
+		if unique(i) {
+			if hits == nil {
+				hits = make([]int, 0, 8)
 			}
+			hits = append(hits, i)
 		}
-	}
+		return true
+	})
 
 	return hits
 }
@@ -277,25 +668,31 @@ func match(in []byte, n *node, unique func(f *node) bool) []int {
 // MatchThreadSafe provides the same result as Match() but does it in a
 // thread-safe manner. Uses a sync.Pool of haystacks to track the uniqueness of
 // the result items.
+//
+// If the Matcher was created WithAllOccurrences() no haystack is
+// needed and none is taken from the pool.
 func (m *Matcher) MatchThreadSafe(in []byte) []int {
 	var (
 		heap map[int]uint64
 	)
 
+	if m.all {
+		return m.match(in, every)
+	}
+
 	generation := atomic.AddUint64(&m.counter, 1)
-	n := m.root
 	// read the matcher's heap
 	item := m.heap.Get()
 	if item == nil {
-		heap = make(map[int]uint64, len(m.trie))
+		heap = make(map[int]uint64, len(m.lens))
 	} else {
 		heap = item.(map[int]uint64)
 	}
 
-	hits := match(in, n, func(f *node) bool {
-		g := heap[f.index]
+	hits := m.match(in, func(i int) bool {
+		g := heap[i]
 		if g != generation {
-			heap[f.index] = generation
+			heap[i] = generation
 			return true
 		}
 		return false
@@ -308,25 +705,12 @@ func (m *Matcher) MatchThreadSafe(in []byte) []int {
 // Contains returns true if any string matches. This can be faster
 // than Match() when you do not need to know which words matched.
 func (m *Matcher) Contains(in []byte) bool {
-	n := m.root
-	for _, b := range in {
-		c := int(b)
-		if !n.root {
-			n = n.fails[c]
-		}
-
-		if n.child[c] != nil {
-			f := n.child[c]
-			n = f
+	found := false
 
-			if f.output {
-				return true
-			}
+	m.scan(in, func(int, int) bool {
+		found = true
+		return false
+	})
 
-			for !f.suffix.root {
-				return true
-			}
-		}
-	}
-	return false
+	return found
 }
@@ -328,6 +328,124 @@ func TestContains(t *testing.T) {
 	assert(t, contains == true)
 }
 
+func TestFindAll(t *testing.T) {
+	m := NewStringMatcher([]string{"he", "she", "his", "hers"})
+	hits := m.FindAll([]byte("ushers and she"))
+	assert(t, len(hits) == 5)
+	assert(t, hits[0] == Match{1, 1, 4})
+	assert(t, hits[1] == Match{0, 2, 4})
+	assert(t, hits[2] == Match{3, 2, 6})
+	assert(t, hits[3] == Match{1, 11, 14})
+	assert(t, hits[4] == Match{0, 12, 14})
+
+	hits = m.FindAll([]byte("hehehe"))
+	assert(t, len(hits) == 3)
+	assert(t, hits[0] == Match{0, 0, 2})
+	assert(t, hits[1] == Match{0, 2, 4})
+	assert(t, hits[2] == Match{0, 4, 6})
+
+	hits = m.FindAll([]byte("nothing here"))
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == Match{0, 8, 10})
+
+	hits = m.FindAll([]byte(""))
+	assert(t, len(hits) == 0)
+}
+
+func TestAllOccurrences(t *testing.T) {
+	m := NewStringMatcher([]string{"he", "she", "hers"}, WithAllOccurrences())
+	hits := m.Match([]byte("she said hehe to hers"))
+	assert(t, len(hits) == 6)
+	assert(t, hits[0] == 1)
+	assert(t, hits[1] == 0)
+	assert(t, hits[2] == 0)
+	assert(t, hits[3] == 0)
+	assert(t, hits[4] == 0)
+	assert(t, hits[5] == 2)
+
+	hits = m.MatchThreadSafe([]byte("she said hehe to hers"))
+	assert(t, len(hits) == 6)
+	assert(t, hits[0] == 1)
+	assert(t, hits[1] == 0)
+	assert(t, hits[2] == 0)
+	assert(t, hits[3] == 0)
+	assert(t, hits[4] == 0)
+	assert(t, hits[5] == 2)
+
+	hits = m.Match([]byte("nothing"))
+	assert(t, len(hits) == 0)
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	m := NewStringMatcher([]string{"mozilla", "MAC", "Safari"}, WithCaseInsensitive())
+	hits := m.Match([]byte("MOZILLA/5.0 (macintosh; Intel Mac OS X) safari"))
+	assert(t, len(hits) == 3)
+	assert(t, hits[0] == 0)
+	assert(t, hits[1] == 1)
+	assert(t, hits[2] == 2)
+
+	hits = m.MatchThreadSafe([]byte("MoZiLLa"))
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == 0)
+
+	found := m.FindAll([]byte("xSaFaRi"))
+	assert(t, len(found) == 1)
+	assert(t, found[0] == Match{2, 1, 7})
+
+	assert(t, m.Contains([]byte("a mAc")))
+	assert(t, !m.Contains([]byte("Mozila")))
+
+	m = NewStringMatcher([]string{"mozilla"})
+	assert(t, !m.Contains([]byte("MOZILLA")))
+}
+
+func TestEveryByte(t *testing.T) {
+	var d [][]byte
+	var in []byte
+	for b := 0; b < 256; b++ {
+		d = append(d, []byte{byte(b), byte(255 - b)})
+		in = append(in, byte(b))
+	}
+
+	m := NewMatcher(d)
+	hits := m.FindAll(in)
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == Match{127, 127, 129})
+
+	m = NewMatcher([][]byte{{0, 255}, {1}})
+	hits = m.FindAll([]byte{2, 0, 255, 3, 1, 254})
+	assert(t, len(hits) == 2)
+	assert(t, hits[0] == Match{0, 1, 3})
+	assert(t, hits[1] == Match{1, 4, 5})
+}
+
+func TestDuplicates(t *testing.T) {
+	d := []string{"he", "she", "he", "hers", "He", "he"}
+
+	for _, opts := range [][]Option{nil, {WithCompact()}, {WithDFA()}} {
+		m := NewStringMatcher(d, opts...)
+
+		hits := m.FindAll([]byte("ushers"))
+		assert(t, len(hits) == 5)
+		assert(t, hits[0] == Match{1, 1, 4})
+		assert(t, hits[1] == Match{0, 2, 4})
+		assert(t, hits[2] == Match{2, 2, 4})
+		assert(t, hits[3] == Match{5, 2, 4})
+		assert(t, hits[4] == Match{3, 2, 6})
+
+		indexes := m.Match([]byte("he he"))
+		assert(t, len(indexes) == 3)
+		assert(t, indexes[0] == 0)
+		assert(t, indexes[1] == 2)
+		assert(t, indexes[2] == 5)
+
+		m = NewStringMatcher(d, append(opts, WithCaseInsensitive())...)
+		indexes = m.MatchThreadSafe([]byte("HE"))
+		assert(t, len(indexes) == 4)
+		assert(t, indexes[2] == 4)
+	}
+}
+
 var bytes = []byte("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_7_5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/30.0.1599.101 Safari/537.36")
 var sbytes = string(bytes)
 var dictionary = []string{"Mozilla", "Mac", "Macintosh", "Safari", "Sausage"}
@@ -345,6 +463,12 @@ func BenchmarkMatchThreadSafeWorks(b *testing.B) {
 	}
 }
 
+func BenchmarkFindAllWorks(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		precomputed.FindAll(bytes)
+	}
+}
+
 func BenchmarkContainsWorks(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		hits := make([]int, 0)
@@ -533,3 +657,89 @@ func BenchmarkLargeMatchThreadSafeWorks(b *testing.B) {
 		precomputed6.MatchThreadSafe(bytes2)
 	}
 }
+
+var precomputedCompact = NewStringMatcher(dictionary, WithCompact())
+var precomputedCompact6 = NewStringMatcher(dictionary6, WithCompact())
+
+func BenchmarkCompactMatchWorks(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		precomputedCompact.Match(bytes)
+	}
+}
+
+func BenchmarkCompactContainsWorks(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		precomputedCompact.Contains(bytes)
+	}
+}
+
+func BenchmarkLargeCompactMatchWorks(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		precomputedCompact6.Match(bytes2)
+	}
+}
+
+func BenchmarkLargeCompactMatchThreadSafeWorks(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		precomputedCompact6.MatchThreadSafe(bytes2)
+	}
+}
+
+func BenchmarkBuildLarge(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewStringMatcher(dictionary6)
+	}
+}
+
+func BenchmarkBuildLargeCompact(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewStringMatcher(dictionary6, WithCompact())
+	}
+}
+
+// dictionary7 has long entries, such as URLs, sharing long prefixes
+var dictionary7 = func() []string {
+	var d []string
+	for i := 0; i < 100; i++ {
+		d = append(d, strings.Repeat(sbytes2[i:i+50], 20)+strings.Repeat("x", i))
+	}
+	return d
+}()
+
+func BenchmarkBuildLongEntries(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewStringMatcher(dictionary7)
+	}
+}
+
+var precomputedDFA = NewStringMatcher(dictionary, WithDFA())
+var precomputedDFA4 = NewStringMatcher(dictionary4, WithDFA())
+var precomputedDFA6 = NewStringMatcher(dictionary6, WithDFA())
+
+func BenchmarkDFAMatchWorks(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		precomputedDFA.Match(bytes)
+	}
+}
+
+func BenchmarkLongDFAMatchFails(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		precomputedDFA4.Match(bytes2)
+	}
+}
+
+func BenchmarkLargeDFAMatchWorks(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		precomputedDFA6.Match(bytes2)
+	}
+}
+
+func BenchmarkBuildLargeDFA(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewStringMatcher(dictionary6, WithDFA())
+	}
+}
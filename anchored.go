@@ -0,0 +1,75 @@
+// anchored.go: matching dictionary entries at the start or end of the
+// input, or against the whole of it, as is needed for routing on host
+// names and paths.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"unicode/utf8"
+)
+
+// MatchPrefix returns the indexes of the dictionary entries which in
+// starts with, shortest first. Only as much of in as the longest entry
+// could cover is looked at.
+//
+// Like FindAll() this is thread-safe.
+func (m *Matcher) MatchPrefix(in []byte) []int {
+	return m.anchored(in, 0, min(m.span(), len(in)), func(h Match) bool {
+		return h.Start == 0
+	})
+}
+
+// MatchSuffix returns the indexes of the dictionary entries which in
+// ends with, longest first. Only as much of in as the longest entry
+// could cover is looked at.
+//
+// Like FindAll() this is thread-safe.
+func (m *Matcher) MatchSuffix(in []byte) []int {
+	return m.anchored(in, max(len(in)-m.span(), 0), len(in), func(h Match) bool {
+		return h.End == len(in)
+	})
+}
+
+// MatchExact returns the indexes of the dictionary entries which are
+// the same as in, in the order of their indexes. There is more than one
+// only if the dictionary has duplicates.
+//
+// Like FindAll() this is thread-safe.
+func (m *Matcher) MatchExact(in []byte) []int {
+	if len(in) > m.span() {
+		return nil
+	}
+
+	return m.anchored(in, 0, len(in), func(h Match) bool {
+		return h.Start == 0 && h.End == len(in)
+	})
+}
+
+// anchored scans in[from:to], with from moved back to the start of a
+// rune, and returns the indexes of the occurrences found for which keep
+// returns true. The offsets of the occurrences given to keep are in
+// `in`.
+func (m *Matcher) anchored(in []byte, from, to int, keep func(Match) bool) []int {
+	for from > 0 && !utf8.RuneStart(in[from]) {
+		from--
+	}
+
+	var hits []int
+
+	part := in[from:to]
+	m.scanAfter(in[:from], part, func(i, end int) bool {
+		h := Match{i, from + m.start(part, i, end), from + end}
+
+		// The end of part is only the end of the input if to is,
+		// so the word boundary there has to be checked again
+
+		if keep(h) && (m.isWord == nil || m.atBoundaries(nil, in, h.Start, h.End)) {
+			hits = append(hits, i)
+		}
+		return true
+	})
+
+	return hits
+}
@@ -0,0 +1,89 @@
+// anchored_test.go: test suite for anchored matching
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"testing"
+)
+
+func TestMatchPrefix(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithCompact()}, {WithDFA()}} {
+		m := NewStringMatcher([]string{"/api/", "/api/v1/", "/", "/static/", "api"}, opts...)
+
+		hits := m.MatchPrefix([]byte("/api/v1/users"))
+		assert(t, len(hits) == 3)
+		assert(t, hits[0] == 2)
+		assert(t, hits[1] == 0)
+		assert(t, hits[2] == 1)
+
+		hits = m.MatchPrefix([]byte("api/v1"))
+		assert(t, len(hits) == 1)
+		assert(t, hits[0] == 4)
+
+		assert(t, m.MatchPrefix([]byte("x/api/")) == nil)
+		assert(t, m.MatchPrefix(nil) == nil)
+	}
+}
+
+func TestMatchSuffix(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithCompact()}, {WithDFA()}} {
+		m := NewStringMatcher([]string{".com", "example.com", "com", ".org"}, opts...)
+
+		hits := m.MatchSuffix([]byte("www.example.com"))
+		assert(t, len(hits) == 3)
+		assert(t, hits[0] == 1)
+		assert(t, hits[1] == 0)
+		assert(t, hits[2] == 2)
+
+		assert(t, m.MatchSuffix([]byte("example.com.au")) == nil)
+		assert(t, m.MatchSuffix([]byte("om")) == nil)
+	}
+}
+
+func TestMatchExact(t *testing.T) {
+	m := NewStringMatcher([]string{"GET", "POST", "PUT", "GET"}, WithCaseInsensitive())
+
+	hits := m.MatchExact([]byte("get"))
+	assert(t, len(hits) == 2)
+	assert(t, hits[0] == 0)
+	assert(t, hits[1] == 3)
+
+	hits = m.MatchExact([]byte("Post"))
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == 1)
+
+	assert(t, m.MatchExact([]byte("GETS")) == nil)
+	assert(t, m.MatchExact([]byte("xPUT")) == nil)
+	assert(t, m.MatchExact([]byte("a much longer input than any entry")) == nil)
+}
+
+func TestMatchAnchoredUnicode(t *testing.T) {
+	m := NewStringMatcher([]string{"straße", "ß", "STRASSE"}, WithUnicodeFolding())
+
+	hits := m.MatchPrefix([]byte("STRAẞE und mehr"))
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == 0)
+
+	hits = m.MatchSuffix([]byte("eine lange Hauptstraße"))
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == 0)
+
+	hits = m.MatchSuffix([]byte("GROẞ"))
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == 1)
+
+	hits = m.MatchExact([]byte("strasse"))
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == 2)
+}
+
+func TestMatchAnchoredWords(t *testing.T) {
+	m := NewStringMatcher([]string{"cat", "dog"}, WithWordBoundaries(IsASCIIWord))
+
+	assert(t, m.MatchPrefix([]byte("cats")) == nil)
+	assert(t, len(m.MatchPrefix([]byte("cat s"))) == 1)
+	assert(t, m.MatchSuffix([]byte("hotdog")) == nil)
+	assert(t, len(m.MatchSuffix([]byte("hot dog"))) == 1)
+}
@@ -0,0 +1,350 @@
+// builder.go: keeping a dictionary which changes over time, such as a
+// blocklist, and building Matchers from it as it changes.
+//
+// Rebuilding the automaton for a large dictionary every time a few
+// entries change is slow, so a Builder only builds an automaton for the
+// entries added since its last full build. The Matchers it builds walk
+// that alongside the automaton of the full build, which they share,
+// and skip the entries of it which have been removed.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"sort"
+)
+
+// deltaRatio controls how often a Builder builds the whole automaton:
+// it does so once more than one in deltaRatio entries of the
+// dictionary have been added or removed since it last did
+const deltaRatio = 8
+
+// Builder keeps a dictionary that entries are added to and removed
+// from, and builds Matchers for it. An entry keeps the same index in
+// the dictionary for as long as it is in it, so the results of Matchers
+// built at different times can be compared. The index of a removed
+// entry may be given to one added later.
+//
+// Each Matcher built is independent of the Builder and of the others,
+// so one can be used while the next is built. A Builder is not safe for
+// concurrent use.
+type Builder struct {
+	opts []Option // Options for the Matchers built
+
+	dictionary [][]byte // The entries, with nil for a removed one
+
+	index map[string]int // The index of each entry in dictionary
+
+	free []int // Indexes of removed entries which can be reused
+
+	base *Matcher // The Matcher last built from scratch, or nil
+
+	entries [][]byte // The dictionary base was built from
+
+	added map[int]bool // The entries added since base was built
+
+	removed []bool // true for each entry of base removed since it was
+	// built
+	unused int // The number of entries of removed which are true
+}
+
+// NewBuilder creates a Builder with an empty dictionary whose Matchers
+// are created with the given options
+func NewBuilder(opts ...Option) *Builder {
+	return &Builder{opts: opts, index: make(map[string]int)}
+}
+
+// Add adds blice to the dictionary if it is not already there and
+// returns its index. Empty blices are never matched and are not added;
+// -1 is returned for them.
+func (b *Builder) Add(blice []byte) int {
+	if len(blice) == 0 {
+		return -1
+	}
+
+	if i, ok := b.index[string(blice)]; ok {
+		return i
+	}
+
+	i := len(b.dictionary)
+	if len(b.free) > 0 {
+		i = b.free[len(b.free)-1]
+		b.free = b.free[:len(b.free)-1]
+	} else {
+		b.dictionary = append(b.dictionary, nil)
+	}
+
+	b.dictionary[i] = append([]byte(nil), blice...)
+	b.index[string(blice)] = i
+
+	// An entry removed and added again under the same index is found
+	// by base as it was before
+
+	if b.base != nil {
+		if i < len(b.removed) && b.removed[i] && string(b.entries[i]) == string(blice) {
+			b.removed[i] = false
+			b.unused--
+		} else {
+			b.added[i] = true
+		}
+	}
+
+	return i
+}
+
+// AddString adds a string to the dictionary as Add does
+func (b *Builder) AddString(s string) int {
+	return b.Add([]byte(s))
+}
+
+// Remove removes blice from the dictionary and returns true if it was
+// there
+func (b *Builder) Remove(blice []byte) bool {
+	i, ok := b.index[string(blice)]
+	if !ok {
+		return false
+	}
+
+	delete(b.index, string(blice))
+	b.dictionary[i] = nil
+	b.free = append(b.free, i)
+
+	if b.base != nil {
+		if b.added[i] {
+			delete(b.added, i)
+		} else {
+			b.removed[i] = true
+			b.unused++
+		}
+	}
+
+	return true
+}
+
+// RemoveString removes a string from the dictionary as Remove does
+func (b *Builder) RemoveString(s string) bool {
+	return b.Remove([]byte(s))
+}
+
+// Index returns the index of blice in the dictionary, or -1 if it is
+// not there
+func (b *Builder) Index(blice []byte) int {
+	if i, ok := b.index[string(blice)]; ok {
+		return i
+	}
+
+	return -1
+}
+
+// Len returns the number of entries in the dictionary
+func (b *Builder) Len() int {
+	return len(b.index)
+}
+
+// Build creates a Matcher for the dictionary as it is now. The indexes
+// it returns are those given by Add.
+//
+// Only the entries added since the last full build are put into a new
+// automaton, so building takes time linear in their length rather than
+// in that of the whole dictionary, plus a little for each entry to
+// copy their lengths. The automaton of the last full build is shared,
+// and matching with both costs more than with one. A full build is done
+// by the first call and whenever more than an eighth of the dictionary
+// has changed since the last one.
+func (b *Builder) Build() *Matcher {
+	if b.base == nil || deltaRatio*(len(b.added)+b.unused) > len(b.index) {
+		return b.Rebuild()
+	}
+
+	m := new(Matcher)
+	for _, opt := range b.opts {
+		opt(m)
+	}
+
+	m.base = b.base
+	m.removed = append([]bool(nil), b.removed...)
+	m.longest = b.base.longest
+
+	m.lens = make([]int, len(b.dictionary))
+	copy(m.lens, b.base.lens)
+
+	for i := range b.added {
+		m.added = append(m.added, i)
+	}
+	sort.Ints(m.added)
+
+	if len(m.added) > 0 {
+		d := make([][]byte, len(m.added))
+		for k, i := range m.added {
+			d[k] = b.dictionary[i]
+		}
+
+		opts := append(b.opts[:len(b.opts):len(b.opts)], withTrie())
+		m.delta = NewMatcher(d, opts...)
+
+		for k, i := range m.added {
+			m.lens[i] = m.delta.lens[k]
+		}
+		m.longest = max(m.longest, m.delta.longest)
+	}
+
+	m.seen = make([]uint64, len(m.lens))
+
+	return m
+}
+
+// Rebuild creates a Matcher for the dictionary as it is now from
+// scratch, as NewMatcher does. Later calls to Build only build the
+// entries added after it. Unlike those built by Build the Matcher can
+// be serialized with MarshalBinary.
+func (b *Builder) Rebuild() *Matcher {
+	b.base = NewMatcher(b.dictionary, b.opts...)
+	b.entries = append([][]byte(nil), b.dictionary...)
+	b.added = make(map[int]bool)
+	b.removed = make([]bool, len(b.dictionary))
+	b.unused = 0
+
+	return b.base
+}
+
+// withTrie makes the Matcher use the default automaton whatever other
+// options say, as the delta of a Builder is walked a byte at a time
+// through its nodes
+func withTrie() Option {
+	return func(m *Matcher) {
+		m.compact = false
+		m.dfa = false
+	}
+}
+
+// walkLayered is walk() for a Matcher built by Builder.Build. The
+// automaton of m.base is walked, skipping the entries which have been
+// removed, and the trie of m.delta is stepped through alongside it so
+// that the occurrences of both are passed to fn in the order walk()
+// gives them. The low 32 bits of s are the state of m.base and the high
+// 32 bits are that of m.delta.
+func (m *Matcher) walkLayered(in []byte, s uint64, fn func(index, end int) bool) uint64 {
+	d := m.delta
+	if d == nil {
+		return uint64(m.base.walkEntries(in, uint32(s), func(i, end int) bool {
+			return m.removed[i] || fn(i, end)
+		}))
+	}
+
+	// The delta is tracked by the ids of its nodes rather than pointers
+	// to them, as the closures below would otherwise pay for a write
+	// barrier at every byte
+
+	var (
+		n   = int(s >> 32) // The node of d reached after in[:pos]
+		pos = 0
+
+		f = -1 // The node of the next entry of d ending at pos which has
+		// not been passed to fn, or -1 if there is none
+		k = -1 // The index in d of that entry
+	)
+
+	// flush passes the entries of d ending at pos to fn, up to those
+	// that come after the entry i of m.base, which has length l. All
+	// of them are passed if l is -1.
+
+	flush := func(l, i int) bool {
+		for ; f >= 0; f, k = d.nextOutput(f, k) {
+			j := m.added[k]
+			if l >= 0 && (d.lens[k] < l || d.lens[k] == l && j > i) {
+				break
+			}
+			if !fn(j, pos) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// catchUp steps d through `in` up to end, passing the entries found
+	// before end to fn and leaving those ending at it to flush
+
+	catchUp := func(end int) bool {
+		for ; pos < end; pos++ {
+			if f >= 0 && !flush(-1, 0) {
+				return false
+			}
+			n = d.step(n, in[pos])
+			f, k = d.firstOutput(n)
+		}
+		return true
+	}
+
+	more := true
+	bs := m.base.walkEntries(in, uint32(s), func(i, end int) bool {
+		if m.removed[i] {
+			return true
+		}
+		more = catchUp(end) && flush(m.base.lens[i], i) && fn(i, end)
+		return more
+	})
+
+	if more && catchUp(len(in)) {
+		flush(-1, 0)
+	}
+
+	return uint64(n)<<32 | uint64(bs)
+}
+
+// step returns the id of the node of the trie reached from node n on
+// the byte b
+func (m *Matcher) step(n int, b byte) int {
+	p := &m.trie[n]
+	if p.root {
+		if f := m.starts[b]; f != nil {
+			return int(f.id)
+		}
+		return n
+	}
+
+	c := m.classes[b]
+	if f := p.child[c]; f != nil {
+		return int(f.id)
+	}
+
+	p = p.fails[c]
+	if f := p.child[c]; f != nil {
+		return int(f.id)
+	}
+	return int(p.id)
+}
+
+// firstOutput returns the first dictionary entry output on reaching
+// node n of the trie, along with the id of the node it belongs to, or
+// -1 and -1 if there is none
+func (m *Matcher) firstOutput(n int) (int, int) {
+	p := &m.trie[n]
+	if p.root {
+		return -1, -1
+	}
+
+	if !p.output {
+		p = p.suffix
+		if p.root {
+			return -1, -1
+		}
+	}
+
+	return int(p.id), p.index
+}
+
+// nextOutput returns the dictionary entry output after entry k, which
+// belongs to node f of the trie, as firstOutput() does
+func (m *Matcher) nextOutput(f, k int) (int, int) {
+	if m.dups != nil && m.dups[k] >= 0 {
+		return f, m.dups[k]
+	}
+
+	p := m.trie[f].suffix
+	if p.root {
+		return -1, -1
+	}
+
+	return int(p.id), p.index
+}
@@ -0,0 +1,228 @@
+// builder_test.go: test suite for Builder
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder()
+	assert(t, b.AddString("Mozilla") == 0)
+	assert(t, b.AddString("Firefox") == 1)
+	assert(t, b.AddString("Phoenix") == 2)
+	assert(t, b.AddString("Mozilla") == 0)
+	assert(t, b.AddString("") == -1)
+	assert(t, b.Len() == 3)
+
+	m := b.Build()
+	hits := m.Match(bytes2)
+	assert(t, len(hits) == 3)
+	assert(t, hits[0] == 1)
+	assert(t, hits[1] == 0)
+	assert(t, hits[2] == 2)
+
+	assert(t, b.RemoveString("Firefox"))
+	assert(t, !b.RemoveString("Firefox"))
+	assert(t, b.Index([]byte("Firefox")) == -1)
+	assert(t, b.Len() == 2)
+
+	n := b.Build()
+	hits = n.Match(bytes2)
+	assert(t, len(hits) == 2)
+	assert(t, hits[0] == 0)
+	assert(t, hits[1] == 2)
+
+	// Matchers already built are not affected
+
+	assert(t, len(m.Match(bytes2)) == 3)
+
+	// The index of the removed entry is reused
+
+	assert(t, b.AddString("Gecko") == 1)
+	assert(t, b.Index([]byte("Gecko")) == 1)
+
+	hits = b.Build().Match(bytes2)
+	assert(t, len(hits) == 3)
+	assert(t, hits[0] == 0)
+	assert(t, hits[1] == 1)
+	assert(t, hits[2] == 2)
+}
+
+func TestBuilderSameAsNewMatcher(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithCompact()}, {WithDFA(), WithCaseInsensitive()}} {
+		b := NewBuilder(opts...)
+		for _, s := range dictionary6 {
+			b.AddString(s)
+		}
+		for _, s := range dictionary5 {
+			b.AddString(s)
+		}
+		for _, s := range dictionary5 {
+			b.RemoveString(s)
+		}
+
+		var d []string
+		for _, s := range dictionary6 {
+			if b.Index([]byte(s)) >= 0 {
+				d = append(d, s)
+			}
+		}
+
+		m := NewStringMatcher(d, opts...)
+		hits1 := m.FindAll(bytes2)
+		hits2 := b.Build().FindAll(bytes2)
+		assert(t, len(hits1) == len(hits2))
+		for i := range hits1 {
+			assert(t, d[hits1[i].Pattern] == dictionary6[hits2[i].Pattern])
+			assert(t, hits1[i].Start == hits2[i].Start)
+			assert(t, hits1[i].End == hits2[i].End)
+		}
+	}
+}
+
+func TestBuilderDelta(t *testing.T) {
+	upper := []byte(strings.ToUpper(string(bytes2)))
+
+	for _, opts := range [][]Option{
+		nil,
+		{WithCompact()},
+		{WithDFA(), WithCaseInsensitive()},
+		{WithUnicodeFolding()},
+		{WithWordBoundaries(IsASCIIWord)},
+		{WithAllOccurrences()},
+	} {
+		b := NewBuilder(opts...)
+		for _, s := range dictionary6 {
+			b.AddString(s)
+		}
+		b.Build()
+
+		layered := 0
+		for r := 0; r < 40; r++ {
+			switch r % 4 {
+			case 0:
+				b.RemoveString(dictionary6[r*7%len(dictionary6)])
+			case 1:
+				b.AddString(dictionary5[r%len(dictionary5)])
+			case 2:
+				b.AddString(strings.ToUpper(dictionary6[r*3%len(dictionary6)]))
+			case 3:
+				b.AddString(dictionary6[(r-3)*7%len(dictionary6)])
+			}
+
+			m := b.Build()
+			if m.base != nil {
+				layered++
+			}
+
+			want := NewMatcher(b.dictionary, opts...)
+			for _, in := range [][]byte{bytes2, upper} {
+				hits1, hits2 := want.FindAll(in), m.FindAll(in)
+				assert(t, len(hits1) == len(hits2))
+				for i := range hits1 {
+					assert(t, hits1[i] == hits2[i])
+				}
+
+				indexes1, indexes2 := want.Match(in), m.Match(in)
+				assert(t, len(indexes1) == len(indexes2))
+				for i := range indexes1 {
+					assert(t, indexes1[i] == indexes2[i])
+				}
+
+				// Streams carry the states of both automata between
+				// Writes
+
+				var streamed []Match
+				s := m.NewStream(func(h Match) {
+					streamed = append(streamed, h)
+				})
+				for i := 0; i < len(in); i += 7 {
+					s.Write(in[i:min(i+7, len(in))])
+				}
+				s.Close()
+				assert(t, len(streamed) == len(hits1))
+				for i := range streamed {
+					assert(t, streamed[i] == hits1[i])
+				}
+			}
+		}
+
+		assert(t, layered > 0)
+		assert(t, layered < 40)
+	}
+}
+
+func TestBuilderReAdd(t *testing.T) {
+	b := NewBuilder()
+	for _, s := range dictionary6 {
+		b.AddString(s)
+	}
+	b.Build()
+
+	i := b.Index([]byte("Firefox"))
+	b.RemoveString("Firefox")
+	assert(t, b.AddString("Firefox") == i)
+
+	// base still has the entry, so nothing needs to be built
+
+	m := b.Build()
+	assert(t, m.base != nil)
+	assert(t, m.delta == nil)
+	assert(t, len(m.Match([]byte("Firefox"))) == 1)
+}
+
+func TestBuilderSerialize(t *testing.T) {
+	b := NewBuilder()
+	for _, s := range dictionary6 {
+		b.AddString(s)
+	}
+	b.Build()
+	b.AddString("Iceweasel")
+
+	m := b.Build()
+	assert(t, m.base != nil)
+	_, err := m.MarshalBinary()
+	assert(t, err == ErrShared)
+
+	m = b.Rebuild()
+	l := roundTrip(t, m)
+	sameMatches(t, m, l, bytes2)
+}
+
+func BenchmarkBuilderDelta(b *testing.B) {
+	bl := NewBuilder()
+	for _, s := range dictionary6 {
+		bl.AddString(s)
+	}
+	bl.Build()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bl.RemoveString("Firefox")
+		bl.AddString("Iceweasel")
+		bl.Build()
+
+		bl.RemoveString("Iceweasel")
+		bl.AddString("Firefox")
+	}
+}
+
+func BenchmarkBuilderDeltaMatchWorks(b *testing.B) {
+	bl := NewBuilder()
+	for _, s := range dictionary6 {
+		bl.AddString(s)
+	}
+	bl.Build()
+	bl.RemoveString("Firefox")
+	bl.AddString("Iceweasel")
+	m := bl.Build()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Match(bytes2)
+	}
+}
@@ -0,0 +1,214 @@
+// compact.go: a compact representation of the automaton for large
+// dictionaries. The default representation gives every node of the
+// trie a transition for each of the 256 possible bytes, which costs
+// about 4KB per node. This one only stores the edges of the trie, in
+// flat arrays, and follows fail links while matching to find the
+// other transitions.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+// WithCompact makes the Matcher use a compact representation of its
+// automaton, which needs a few tens of bytes per node of the trie
+// rather than kilobytes. Matching gives the same results but is slower,
+// as fail links have to be followed to find transitions. It replaces
+// WithDFA() if both are given.
+func WithCompact() Option {
+	return func(m *Matcher) {
+		m.compact = true
+		m.dfa = false
+	}
+}
+
+// compact is an automaton which only stores the edges of the trie
+type compact struct {
+
+	// The edges out of state s are labelled with the bytes in
+	// labels[first[s]:first[s+1]], which are sorted, and lead to the
+	// states in the same entries of next
+
+	first  []uint32
+	labels []byte
+	next   []uint32
+
+	root [256]uint32 // The state reached from the root for each byte,
+	// or 0 if the root has no edge for it
+
+	fail []uint32 // The state representing the longest strict suffix
+	// of each state that is in the trie
+
+	suffix []uint32 // The next state along the fail links which is a
+	// dictionary entry, or 0 if there is none
+
+	index []int32 // The index of the dictionary entry each state
+	// represents, or -1 if it does not represent one
+
+	fold bool // true if ASCII letters in the input are folded to
+	// lower case
+}
+
+// newCompact builds a compact automaton for m from a dictionary which
+// has already been through Matcher.prepare()
+func newCompact(m *Matcher, dictionary [][]byte) *compact {
+	type edge struct {
+		label byte
+		next  uint32
+	}
+
+	// Build the trie with the edges of each state in a slice of their
+	// own, as they are not known in advance
+
+	edges := [][]edge{nil}
+	index := []int32{-1}
+
+	for i, blice := range dictionary {
+		if len(blice) == 0 {
+			continue
+		}
+
+		s := uint32(0)
+		for _, b := range blice {
+			t := uint32(0)
+			for _, e := range edges[s] {
+				if e.label == b {
+					t = e.next
+					break
+				}
+			}
+
+			if t == 0 {
+				t = uint32(len(edges))
+				edges = append(edges, nil)
+				index = append(index, -1)
+				edges[s] = append(edges[s], edge{b, t})
+			}
+
+			s = t
+		}
+
+		if index[s] >= 0 {
+			m.duplicate(int(index[s]), i)
+			continue
+		}
+
+		index[s] = int32(i)
+	}
+
+	// Flatten the edges into arrays, sorting those of each state by
+	// label so that they can be searched
+
+	c := &compact{
+		first:  make([]uint32, len(edges)+1),
+		labels: make([]byte, 0, len(edges)-1),
+		next:   make([]uint32, 0, len(edges)-1),
+		fail:   make([]uint32, len(edges)),
+		suffix: make([]uint32, len(edges)),
+		index:  index,
+		fold:   m.fold,
+	}
+
+	for s, es := range edges {
+		for i := 1; i < len(es); i++ {
+			for j := i; j > 0 && es[j].label < es[j-1].label; j-- {
+				es[j], es[j-1] = es[j-1], es[j]
+			}
+		}
+
+		for _, e := range es {
+			c.labels = append(c.labels, e.label)
+			c.next = append(c.next, e.next)
+		}
+		c.first[s+1] = uint32(len(c.labels))
+	}
+
+	for _, e := range edges[0] {
+		c.root[e.label] = e.next
+	}
+
+	// Work out the fail and suffix links breadth first, so that those
+	// of every shorter state are known. The fail link of a state is
+	// found by following the edge with the same label from the fail
+	// state of its parent.
+
+	queue := make([]uint32, 1, len(edges))
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+
+		for e := c.first[s]; e < c.first[s+1]; e++ {
+			t := c.next[e]
+			queue = append(queue, t)
+
+			if s != 0 {
+				c.fail[t] = c.step(c.fail[s], c.labels[e])
+			}
+
+			f := c.fail[t]
+			if c.index[f] >= 0 {
+				c.suffix[t] = f
+			} else {
+				c.suffix[t] = c.suffix[f]
+			}
+		}
+	}
+
+	return c
+}
+
+// child returns the state reached by the edge labelled b out of state
+// s, or 0 if there is no such edge
+func (c *compact) child(s uint32, b byte) uint32 {
+	lo, hi := c.first[s], c.first[s+1]
+
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		switch {
+		case c.labels[mid] < b:
+			lo = mid + 1
+		case c.labels[mid] > b:
+			hi = mid
+		default:
+			return c.next[mid]
+		}
+	}
+
+	return 0
+}
+
+// step returns the state reached from state s on byte b, following
+// fail links until a state with an edge for b is found
+func (c *compact) step(s uint32, b byte) uint32 {
+	for s != 0 {
+		if t := c.child(s, b); t != 0 {
+			return t
+		}
+		s = c.fail[s]
+	}
+
+	return c.root[b]
+}
+
+func (c *compact) walk(in []byte, s uint32, fn func(index, end int) bool) uint32 {
+	for i, b := range in {
+		if c.fold {
+			b = lower(b)
+		}
+
+		s = c.step(s, b)
+
+		f := s
+		if c.index[f] < 0 {
+			f = c.suffix[f]
+		}
+
+		for f != 0 {
+			if !fn(int(c.index[f]), i+1) {
+				return s
+			}
+			f = c.suffix[f]
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,93 @@
+// compact_test.go: test suite for the compact automaton
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"testing"
+)
+
+// sameMatches checks that two Matchers find the same occurrences in in
+func sameMatches(t *testing.T, m1, m2 *Matcher, in []byte) {
+	hits1 := m1.FindAll(in)
+	hits2 := m2.FindAll(in)
+	assert(t, len(hits1) == len(hits2))
+	for i := range hits1 {
+		assert(t, i < len(hits2) && hits1[i] == hits2[i])
+	}
+
+	assert(t, m1.Contains(in) == m2.Contains(in))
+}
+
+// automata are the options selecting each automaton other than the
+// default one
+var automata = [][]Option{{WithCompact()}, {WithDFA()}}
+
+func TestAutomataSameAsDefault(t *testing.T) {
+	dictionaries := [][]string{
+		dictionary, dictionary2, dictionary3, dictionary4, dictionary5, dictionary6,
+		{"a", "ab", "bc", "bca", "c", "caa"},
+		{"Superman", "uperman", "perman", "erman"},
+		{"", "he", "she", "his", "hers"},
+		{},
+	}
+	inputs := [][]byte{bytes, bytes2, []byte("abccab"), []byte("ushers Superman"), []byte("")}
+
+	for _, automaton := range automata {
+		for _, d := range dictionaries {
+			for _, opts := range [][]Option{nil, {WithCaseInsensitive()}, {WithUnicodeFolding()}} {
+				m1 := NewStringMatcher(d, opts...)
+				m2 := NewStringMatcher(d, append(opts, automaton...)...)
+
+				for _, in := range inputs {
+					sameMatches(t, m1, m2, in)
+				}
+			}
+		}
+	}
+}
+
+func TestAutomataMatch(t *testing.T) {
+	for _, automaton := range automata {
+		m := NewStringMatcher([]string{"a", "ab", "bc", "bca", "c", "caa"}, automaton...)
+		hits := m.Match([]byte("abccab"))
+		assert(t, len(hits) == 4)
+		assert(t, hits[0] == 0)
+		assert(t, hits[1] == 1)
+		assert(t, hits[2] == 2)
+		assert(t, hits[3] == 4)
+
+		hits = m.MatchThreadSafe([]byte("bccab"))
+		assert(t, len(hits) == 4)
+		assert(t, hits[0] == 2)
+		assert(t, hits[1] == 4)
+		assert(t, hits[2] == 0)
+		assert(t, hits[3] == 1)
+
+		assert(t, m.Contains([]byte("xxc")))
+		assert(t, !m.Contains([]byte("xxx")))
+	}
+}
+
+func TestCompactStream(t *testing.T) {
+	m := NewStringMatcher(dictionary6, WithCompact())
+	want := m.FindAll(bytes2)
+
+	var hits []Match
+	s := m.NewStream(func(h Match) {
+		hits = append(hits, h)
+	})
+	for i := 0; i < len(bytes2); i += 5 {
+		j := i + 5
+		if j > len(bytes2) {
+			j = len(bytes2)
+		}
+		s.Write(bytes2[i:j])
+	}
+
+	assert(t, len(hits) == len(want))
+	for i := range want {
+		assert(t, hits[i] == want[i])
+	}
+}
@@ -0,0 +1,120 @@
+// dfa.go: a fully deterministic representation of the automaton for
+// when matching speed matters more than memory or build time. The
+// transition for every state and byte class is worked out in advance
+// and stored in a flat table, so matching each byte of input is a
+// single table lookup with no fail links to follow.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+// WithDFA makes the Matcher compile its trie into a deterministic
+// automaton whose transitions are stored in a flat table. Matching is
+// much faster when the input keeps the automaton away from its root,
+// as with large dictionaries, but building takes longer and the table
+// needs four bytes for every byte class of every node in the trie. It
+// replaces WithCompact() if both are given.
+//
+// States are numbered by their offset in the table, which must be less
+// than 1<<31, so the number of nodes times the number of byte classes
+// is limited to that. A dictionary too large for it gets the default
+// automaton instead.
+func WithDFA() Option {
+	return func(m *Matcher) {
+		m.dfa = true
+		m.compact = false
+	}
+}
+
+// dfaOutput is set in an entry of the transition table when the state
+// it leads to has outputs
+const dfaOutput = 1 << 31
+
+// dfa is an automaton which has every transition in a single table
+type dfa struct {
+	classes [256]byte // The class of each byte, as in the trie
+	stride  uint32    // The number of classes
+
+	next []uint32 // The state reached from state s on a byte of
+	// class c is next[s+c], or'd with dfaOutput if that state has
+	// outputs. States are numbered by the offset of their row in the
+	// table, which is the id of their node times stride, to save a
+	// multiplication for every byte of input.
+
+	// The indexes of the dictionary entries which end at the state of
+	// node i are out[first[i]:first[i+1]], longest first
+
+	first []uint32
+	out   []uint32
+}
+
+// dfaFits returns true if a dfa for a trie of the given number of
+// nodes, with stride byte classes, can number its states
+func dfaFits(nodes, stride int) bool {
+	return uint64(nodes)*uint64(stride) <= dfaOutput
+}
+
+// newDFA builds a dfa from the trie built by Matcher.buildTrie()
+func newDFA(m *Matcher) *dfa {
+	d := &dfa{
+		classes: m.classes,
+		stride:  uint32(m.stride),
+		next:    make([]uint32, len(m.trie)*m.stride),
+		first:   make([]uint32, len(m.trie)+1),
+	}
+
+	for i := range m.trie {
+		n := &m.trie[i]
+
+		// The outputs of a node are its own dictionary entry followed
+		// by those of its suffixes
+
+		if n.output {
+			d.out = append(d.out, uint32(n.index))
+		}
+		for f := n; !n.root && !f.suffix.root; f = f.suffix {
+			d.out = append(d.out, uint32(f.suffix.index))
+		}
+		d.first[i+1] = uint32(len(d.out))
+
+		for c := range n.child {
+			f := n.child[c]
+			if f == nil && !n.root {
+				f = n.fails[c].child[c]
+			}
+
+			if f != nil {
+				d.next[i*m.stride+c] = f.id
+			}
+		}
+	}
+
+	for i, id := range d.next {
+		d.next[i] = id * d.stride
+		if d.first[id] != d.first[id+1] {
+			d.next[i] |= dfaOutput
+		}
+	}
+
+	return d
+}
+
+func (d *dfa) walk(in []byte, s uint32, fn func(index, end int) bool) uint32 {
+	next, classes, stride := d.next, &d.classes, d.stride
+
+	for i, b := range in {
+		t := next[s+uint32(classes[b])]
+		s = t &^ dfaOutput
+
+		if t&dfaOutput != 0 {
+			id := s / stride
+			for _, index := range d.out[d.first[id]:d.first[id+1]] {
+				if !fn(int(index), i+1) {
+					return s
+				}
+			}
+		}
+	}
+
+	return s
+}
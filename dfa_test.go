@@ -0,0 +1,27 @@
+// dfa_test.go: test suite for the dfa automaton
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"testing"
+)
+
+func TestDFAReplacesCompact(t *testing.T) {
+	m := NewStringMatcher(dictionary, WithCompact(), WithDFA())
+	assert(t, m.table != nil)
+	assert(t, m.sparse == nil)
+
+	m = NewStringMatcher(dictionary, WithDFA(), WithCompact())
+	assert(t, m.table == nil)
+	assert(t, m.sparse != nil)
+}
+
+func TestDFAFits(t *testing.T) {
+	assert(t, dfaFits(1, 256))
+	assert(t, dfaFits(1<<23, 256))
+	assert(t, !dfaFits(1<<23+1, 256))
+	assert(t, dfaFits(1<<30, 2))
+	assert(t, !dfaFits(1<<30, 3))
+}
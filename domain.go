@@ -0,0 +1,65 @@
+// domain.go: checking whether host names are in listed domains, such as
+// for a blocklist where example.com covers www.example.com.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+// DomainMatcher finds which of a list of domains a host name is in. A
+// host is in a domain if it is the domain or ends with a dot followed
+// by the domain, so example.com covers a.b.example.com but not
+// badexample.com. Case is ignored and so is a trailing dot, which
+// makes a name fully qualified.
+//
+// Like FindAll() its methods are thread-safe.
+type DomainMatcher struct {
+	m *Matcher
+}
+
+// NewDomainMatcher creates a DomainMatcher for a list of domains. Empty
+// domains are ignored. The options are passed on to NewMatcher along
+// with WithCaseInsensitive(); of the others only WithCompact() and
+// WithDFA() make sense here.
+func NewDomainMatcher(domains []string, opts ...Option) *DomainMatcher {
+	d := make([][]byte, len(domains))
+	for i, domain := range domains {
+		d[i] = trimDots([]byte(domain))
+	}
+
+	return &DomainMatcher{NewMatcher(d, append(opts[:len(opts):len(opts)], WithCaseInsensitive())...)}
+}
+
+// trimDots removes the dots at the start and end of a domain name
+func trimDots(name []byte) []byte {
+	for len(name) > 0 && name[0] == '.' {
+		name = name[1:]
+	}
+	for len(name) > 0 && name[len(name)-1] == '.' {
+		name = name[:len(name)-1]
+	}
+
+	return name
+}
+
+// Lookup returns the index in the list of the most specific domain
+// that host is in, which is the longest one, and true, or false if host
+// is not in any of them
+func (d *DomainMatcher) Lookup(host []byte) (int, bool) {
+	if hits := d.LookupAll(host); len(hits) > 0 {
+		return hits[0], true
+	}
+
+	return -1, false
+}
+
+// LookupAll returns the indexes in the list of all the domains host is
+// in, most specific first
+func (d *DomainMatcher) LookupAll(host []byte) []int {
+	for len(host) > 0 && host[len(host)-1] == '.' {
+		host = host[:len(host)-1]
+	}
+
+	return d.m.anchored(host, max(len(host)-d.m.span(), 0), len(host), func(h Match) bool {
+		return h.End == len(host) && (h.Start == 0 || host[h.Start-1] == '.')
+	})
+}
@@ -0,0 +1,50 @@
+// domain_test.go: test suite for DomainMatcher
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"testing"
+)
+
+func TestDomainMatcher(t *testing.T) {
+	domains := []string{"example.com", "b.example.com.", "com", "Example.ORG", ".net", ""}
+
+	for _, opts := range [][]Option{nil, {WithCompact()}, {WithDFA()}} {
+		d := NewDomainMatcher(domains, opts...)
+
+		i, ok := d.Lookup([]byte("a.b.example.com"))
+		assert(t, ok && i == 1)
+
+		hits := d.LookupAll([]byte("A.B.EXAMPLE.COM."))
+		assert(t, len(hits) == 3)
+		assert(t, hits[0] == 1)
+		assert(t, hits[1] == 0)
+		assert(t, hits[2] == 2)
+
+		i, ok = d.Lookup([]byte("example.com"))
+		assert(t, ok && i == 0)
+
+		i, ok = d.Lookup([]byte("badexample.com"))
+		assert(t, ok && i == 2)
+
+		i, ok = d.Lookup([]byte("www.example.org"))
+		assert(t, ok && i == 3)
+
+		i, ok = d.Lookup([]byte("cloudflare.net"))
+		assert(t, ok && i == 4)
+
+		_, ok = d.Lookup([]byte("example.co"))
+		assert(t, !ok)
+
+		_, ok = d.Lookup([]byte("badexample.org"))
+		assert(t, !ok)
+
+		_, ok = d.Lookup([]byte(""))
+		assert(t, !ok)
+
+		_, ok = d.Lookup([]byte("."))
+		assert(t, !ok)
+	}
+}
@@ -0,0 +1,122 @@
+// fold.go: Unicode case-insensitive matching. Both the dictionary and
+// the input are folded rune by rune so that runes which are equal under
+// Unicode simple case folding lead to the same path through the trie.
+// The input is folded as it is matched, one rune at a time, rather
+// than being copied.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// WithUnicodeFolding makes the Matcher ignore case using Unicode simple
+// case folding, as strings.EqualFold does: "Σ" matches "σ" and "ς",
+// and "K" (Kelvin sign) matches "k", but "ß" does not match "ss".
+// Offsets of matches are reported in the original input.
+//
+// Bytes that are not part of valid UTF-8 are matched as they are.
+func WithUnicodeFolding() Option {
+	return func(m *Matcher) {
+		m.unicode = true
+	}
+}
+
+// foldRune returns the smallest rune in the case folding orbit of r so
+// that all runes which fold to each other fold to the same rune
+func foldRune(r rune) rune {
+	if r < utf8.RuneSelf {
+		if r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		return r
+	}
+
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+
+	return min
+}
+
+// foldBlice returns a copy of b with every rune folded
+func foldBlice(b []byte) []byte {
+	f := make([]byte, 0, len(b))
+
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError && size == 1 {
+			f = append(f, b[0])
+		} else {
+			f = utf8.AppendRune(f, foldRune(r))
+		}
+		b = b[size:]
+	}
+
+	return f
+}
+
+// foldedStart returns the offset in `in` at which a dictionary entry
+// whose folded form is n bytes long and which ends at end starts. The
+// folded form of a rune is never longer than the rune itself, but it
+// can be shorter, so this has to work back through the runes.
+func foldedStart(in []byte, end, n int) int {
+	for n > 0 && end > 0 {
+		r, size := utf8.DecodeLastRune(in[:end])
+		if r == utf8.RuneError && size == 1 {
+			n--
+		} else {
+			n -= utf8.RuneLen(foldRune(r))
+		}
+		end -= size
+	}
+
+	return end
+}
+
+// walkFolded is the equivalent of walk() for a Matcher created
+// WithUnicodeFolding(). It starts in state s at offset i in `in` and
+// the ends passed to fn are offsets in `in`. If partial is true a rune
+// which is cut short at the end of `in` is left unconsumed. Returns the
+// state reached and the offset up to which `in` was consumed.
+func (m *Matcher) walkFolded(in []byte, i int, s uint64, partial bool, fn func(index, end int) bool) (uint64, int) {
+	var (
+		buf  [utf8.UTFMax]byte
+		end  int
+		stop bool
+	)
+
+	emit := func(index, _ int) bool {
+		if !fn(index, end) {
+			stop = true
+		}
+		return !stop
+	}
+
+	for i < len(in) && !stop {
+		if partial && !utf8.FullRune(in[i:]) {
+			break
+		}
+
+		r, size := utf8.DecodeRune(in[i:])
+
+		c := buf[:1]
+		if r == utf8.RuneError && size == 1 {
+			c[0] = in[i]
+		} else {
+			c = utf8.AppendRune(buf[:0], foldRune(r))
+		}
+
+		end = i + size
+		s = m.walk(c, s, emit)
+		i = end
+	}
+
+	return s, i
+}
@@ -0,0 +1,91 @@
+// fold_test.go: test suite for Unicode case folding
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"testing"
+)
+
+func TestUnicodeFolding(t *testing.T) {
+	m := NewStringMatcher([]string{"straße", "σοφία", "kelvin", "ǆ"}, WithUnicodeFolding())
+
+	hits := m.Match([]byte("STRAẞE and ΣΟΦΊΑ"))
+	assert(t, len(hits) == 2)
+	assert(t, hits[0] == 0)
+	assert(t, hits[1] == 1)
+
+	hits = m.MatchThreadSafe([]byte("ǅ"))
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == 3)
+
+	assert(t, m.Contains([]byte("KELVIN")))
+	assert(t, !m.Contains([]byte("strasse")))
+}
+
+func TestUnicodeFoldingOffsets(t *testing.T) {
+	m := NewStringMatcher([]string{"kelvin", "σ"}, WithUnicodeFolding())
+
+	// The Kelvin sign is three bytes long but folds to a single byte
+
+	in := []byte("5 Kelvin, Σς")
+	hits := m.FindAll(in)
+	assert(t, len(hits) == 3)
+	assert(t, hits[0] == Match{0, 2, 10})
+	assert(t, string(in[hits[0].Start:hits[0].End]) == "Kelvin")
+	assert(t, hits[1] == Match{1, 12, 14})
+	assert(t, hits[2] == Match{1, 14, 16})
+}
+
+func TestUnicodeFoldingInvalid(t *testing.T) {
+	m := NewMatcher([][]byte{[]byte("\xffA"), []byte("é")}, WithUnicodeFolding())
+
+	hits := m.FindAll([]byte("x\xffa\xc3É"))
+	assert(t, len(hits) == 2)
+	assert(t, hits[0] == Match{0, 1, 3})
+	assert(t, hits[1] == Match{1, 4, 6})
+}
+
+func TestUnicodeFoldingStream(t *testing.T) {
+	m := NewStringMatcher([]string{"kelvin", "ΣΟΦΊΑ"}, WithUnicodeFolding())
+	in := []byte("a Kelvin and σοφία, KELVIN")
+	want := m.FindAll(in)
+	assert(t, len(want) == 3)
+
+	for size := 1; size <= len(in); size++ {
+		var hits []Match
+		s := m.NewStream(func(h Match) {
+			hits = append(hits, h)
+		})
+
+		for i := 0; i < len(in); i += size {
+			j := i + size
+			if j > len(in) {
+				j = len(in)
+			}
+			s.Write(in[i:j])
+		}
+		s.Close()
+
+		assert(t, len(hits) == len(want))
+		for i := range want {
+			assert(t, i < len(hits) && hits[i] == want[i])
+		}
+	}
+}
+
+func TestUnicodeFoldingStreamClose(t *testing.T) {
+	m := NewMatcher([][]byte{[]byte("a\xc3")}, WithUnicodeFolding())
+
+	var hits []Match
+	s := m.NewStream(func(h Match) {
+		hits = append(hits, h)
+	})
+
+	s.Write([]byte("xA\xc3"))
+	assert(t, len(hits) == 0)
+	s.Close()
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == Match{0, 1, 3})
+}
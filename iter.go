@@ -0,0 +1,49 @@
+// iter.go: ranging over the occurrences of dictionary entries as they
+// are found, so that callers which stop early do not pay for finding
+// and storing the rest.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"iter"
+)
+
+// All returns an iterator over the occurrences in `in` that FindAll()
+// would return, in the same order. Each is found as the loop reaches
+// it and nothing is found after the loop ends, so
+//
+//	for h := range m.All(in) {
+//		if interesting(h) {
+//			break
+//		}
+//	}
+//
+// does no more work than it has to and allocates no slice. It is
+// thread-safe, and `in` must not be changed while the loop runs.
+func (m *Matcher) All(in []byte) iter.Seq[Match] {
+	return func(yield func(Match) bool) {
+		m.scan(in, func(i, end int) bool {
+			return yield(Match{i, m.start(in, i, end), end})
+		})
+	}
+}
+
+// AllValues returns an iterator over the occurrences that All() would
+// give, along with the value of each entry found
+func (m *ValueMatcher[T]) AllValues(in []byte) iter.Seq2[Match, T] {
+	return func(yield func(Match, T) bool) {
+		for h := range m.All(in) {
+			if !yield(h, m.values[h.Pattern]) {
+				return
+			}
+		}
+	}
+}
+
+// All calls All() on the Matcher in use when it is called, which is
+// used for the whole loop
+func (s *SwappableMatcher) All(in []byte) iter.Seq[Match] {
+	return s.m.Load().All(in)
+}
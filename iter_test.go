@@ -0,0 +1,99 @@
+// iter_test.go: test suite for the iterators over occurrences
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	tests := []struct {
+		m  *Matcher
+		in string
+	}{
+		{NewStringMatcher([]string{"he", "she", "his", "hers"}), "ushers his shed"},
+		{NewStringMatcher([]string{"a", "a", "aa"}), "aaa"},
+		{NewStringMatcher([]string{"σ", "Straße"}, WithUnicodeFolding()), "Σ STRASSE straße"},
+		{NewStringMatcher([]string{"cat"}, WithWordBoundaries(IsASCIIWord)), "cat concat cat."},
+		{NewStringMatcher([]string{"he", "she", "hers"}, WithDFA()), "ushers"},
+		{NewStringMatcher([]string{"he", "she", "hers"}, WithCompact()), "ushers"},
+	}
+
+	for _, test := range tests {
+		in := []byte(test.in)
+		want := test.m.FindAll(in)
+
+		var got []Match
+		for h := range test.m.All(in) {
+			got = append(got, h)
+		}
+
+		assert(t, len(got) == len(want))
+		for i := range got {
+			assert(t, got[i] == want[i])
+		}
+
+		// Breaking out of the loop stops it
+
+		for n := 0; n < len(want); n++ {
+			count := 0
+			for h := range test.m.All(in) {
+				assert(t, h == want[count])
+				count++
+				if count > n {
+					break
+				}
+			}
+			assert(t, count == n+1)
+		}
+	}
+}
+
+func TestAllValues(t *testing.T) {
+	m := NewStringValueMatcher([]string{"Superman", "Batman"}, []string{"Clark", "Bruce"})
+
+	var names []string
+	for h, name := range m.AllValues([]byte("Batman and Superman and Batman")) {
+		assert(t, h.Pattern == 0 || h.Pattern == 1)
+		names = append(names, name)
+		if len(names) == 2 {
+			break
+		}
+	}
+
+	assert(t, len(names) == 2)
+	assert(t, names[0] == "Bruce")
+	assert(t, names[1] == "Clark")
+
+	s := NewSwappableMatcher(m.Matcher)
+	for h := range s.All([]byte("Batman")) {
+		assert(t, h == Match{1, 0, 6})
+	}
+}
+
+func TestAllAllocs(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		for h := range precomputed6.All(bytes) {
+			if h.Pattern < 0 {
+				break
+			}
+		}
+	})
+	assert(t, allocs == 0)
+}
+
+func BenchmarkAllFirst(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for range precomputed6.All(bytes) {
+			break
+		}
+	}
+}
+
+func BenchmarkFindAllFirst(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = precomputed6.FindAll(bytes)[0]
+	}
+}
@@ -0,0 +1,88 @@
+// leftmost.go: choosing non-overlapping matches, as needed for
+// tokenization and replacement.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"sort"
+)
+
+// MatchKind selects how FindAllNonOverlapping chooses between
+// occurrences which overlap
+type MatchKind int
+
+const (
+	// Standard chooses occurrences in the order the automaton finds
+	// them: the one which ends first wins and of those ending at the
+	// same offset the longest wins. With the dictionary "he", "hers"
+	// the input "hers" gives "he".
+	Standard MatchKind = iota
+
+	// LeftmostFirst chooses the occurrence which starts first and of
+	// those starting at the same offset the one which comes first in
+	// the dictionary. This is how an alternation in a backtracking
+	// regular expression such as Go's regexp package behaves.
+	LeftmostFirst
+
+	// LeftmostLongest chooses the occurrence which starts first and of
+	// those starting at the same offset the longest. With the
+	// dictionary "he", "hers" the input "hers" gives "hers".
+	LeftmostLongest
+)
+
+// WithMatchKind sets how FindAllNonOverlapping chooses between
+// overlapping occurrences. The default is Standard.
+func WithMatchKind(k MatchKind) Option {
+	return func(m *Matcher) {
+		m.kind = k
+	}
+}
+
+// FindAllNonOverlapping searches in for blices and returns occurrences
+// which do not overlap each other, in the order they appear in the
+// input. Where occurrences overlap one is chosen according to the
+// MatchKind the Matcher was created with.
+//
+// Like FindAll() this is thread-safe.
+func (m *Matcher) FindAllNonOverlapping(in []byte) []Match {
+	return m.choose(m.FindAll(in))
+}
+
+// choose picks occurrences which do not overlap from hits, which are
+// in the order FindAll() returns them, as FindAllNonOverlapping()
+// does
+func (m *Matcher) choose(hits []Match) []Match {
+	switch m.kind {
+	case LeftmostFirst:
+		sort.SliceStable(hits, func(i, j int) bool {
+			if hits[i].Start != hits[j].Start {
+				return hits[i].Start < hits[j].Start
+			}
+			return hits[i].Pattern < hits[j].Pattern
+		})
+
+	case LeftmostLongest:
+		sort.SliceStable(hits, func(i, j int) bool {
+			if hits[i].Start != hits[j].Start {
+				return hits[i].Start < hits[j].Start
+			}
+			return hits[i].End > hits[j].End
+		})
+	}
+
+	// The occurrences are now in order of preference, so the first one
+	// which does not overlap the previous choice is chosen each time
+
+	chosen := hits[:0]
+	end := 0
+	for _, h := range hits {
+		if h.Start >= end {
+			chosen = append(chosen, h)
+			end = h.End
+		}
+	}
+
+	return chosen
+}
@@ -0,0 +1,57 @@
+// leftmost_test.go: test suite for non-overlapping matches
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"testing"
+)
+
+func TestNonOverlappingStandard(t *testing.T) {
+	m := NewStringMatcher([]string{"he", "hers", "she"})
+	hits := m.FindAllNonOverlapping([]byte("hers ushers"))
+	assert(t, len(hits) == 2)
+	assert(t, hits[0] == Match{0, 0, 2})
+	assert(t, hits[1] == Match{2, 6, 9})
+}
+
+func TestNonOverlappingLeftmostFirst(t *testing.T) {
+	m := NewStringMatcher([]string{"he", "hers", "she"}, WithMatchKind(LeftmostFirst))
+	hits := m.FindAllNonOverlapping([]byte("hers ushers"))
+	assert(t, len(hits) == 2)
+	assert(t, hits[0] == Match{0, 0, 2})
+	assert(t, hits[1] == Match{2, 6, 9})
+
+	m = NewStringMatcher([]string{"Samwise", "Sam"}, WithMatchKind(LeftmostFirst))
+	hits = m.FindAllNonOverlapping([]byte("Samwise"))
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == Match{0, 0, 7})
+
+	m = NewStringMatcher([]string{"Sam", "Samwise"}, WithMatchKind(LeftmostFirst))
+	hits = m.FindAllNonOverlapping([]byte("Samwise"))
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == Match{0, 0, 3})
+}
+
+func TestNonOverlappingLeftmostLongest(t *testing.T) {
+	m := NewStringMatcher([]string{"he", "hers", "she"}, WithMatchKind(LeftmostLongest))
+	hits := m.FindAllNonOverlapping([]byte("hers ushers"))
+	assert(t, len(hits) == 2)
+	assert(t, hits[0] == Match{1, 0, 4})
+	assert(t, hits[1] == Match{2, 6, 9})
+
+	m = NewStringMatcher([]string{"abcd", "b", "bcdef"}, WithMatchKind(LeftmostLongest))
+	hits = m.FindAllNonOverlapping([]byte("abcdef bcdef"))
+	assert(t, len(hits) == 2)
+	assert(t, hits[0] == Match{0, 0, 4})
+	assert(t, hits[1] == Match{2, 7, 12})
+}
+
+func TestNonOverlappingNoMatches(t *testing.T) {
+	for _, k := range []MatchKind{Standard, LeftmostFirst, LeftmostLongest} {
+		m := NewStringMatcher([]string{"foo"}, WithMatchKind(k))
+		hits := m.FindAllNonOverlapping([]byte("bar baz"))
+		assert(t, len(hits) == 0)
+	}
+}
@@ -0,0 +1,107 @@
+// mmap.go: using a serialized Matcher where it lies in memory, such as
+// a memory mapped file, so that processes loading the same file share
+// one copy of the automaton.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"unsafe"
+)
+
+// ErrNotInPlace is returned by Open when the automaton in the file
+// cannot be used where it is mapped and would have to be rebuilt in the
+// memory of each process. That is the case for the default automaton,
+// which is made of pointers, and for any automaton on a big endian
+// machine. Create the Matcher WithCompact() or WithDFA() to map it.
+var ErrNotInPlace = errors.New("ahocorasick: serialized automaton cannot be used in place")
+
+// Load creates a Matcher from data written by Matcher.MarshalBinary,
+// like UnmarshalBinary, but without copying the automaton: matching
+// runs on data itself, which must not be modified while the Matcher is
+// in use.
+//
+// Only the flat arrays of a Matcher created WithCompact() or WithDFA()
+// can be used in place. The default automaton is made of pointers, so
+// it is rebuilt as UnmarshalBinary would, and so are the others when
+// data is not aligned to four bytes or this is a big endian machine.
+func Load(data []byte) (*Matcher, error) {
+	m := new(Matcher)
+	if err := m.unmarshal(data, inPlace(data)); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// inPlace returns true if the arrays of the automaton in data can be
+// used where they are: they are little endian and aligned to four bytes
+func inPlace(data []byte) bool {
+	return binary.NativeEndian.Uint16([]byte{1, 0}) == 1 &&
+		uintptr(unsafe.Pointer(unsafe.SliceData(data)))%4 == 0
+}
+
+// MappedMatcher is a Matcher loaded from a memory mapped file by Open
+type MappedMatcher struct {
+	*Matcher
+
+	data []byte // The mapped file
+}
+
+// Open memory maps a file written with the result of
+// Matcher.MarshalBinary and loads a Matcher from it as Load does. The
+// pages of the file are shared by every process which opens it. The
+// Matcher must not be used after Close is called.
+//
+// Only a Matcher created WithCompact() or WithDFA() can be opened.
+// ErrNotInPlace is returned for any other, rather than giving each
+// process a copy of its automaton; Load or UnmarshalBinary can be used
+// to load one of those.
+//
+// Where memory mapping is not supported the file is read into memory
+// instead.
+func Open(name string) (*MappedMatcher, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := mapFile(f, int(fi.Size()))
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := Load(data)
+	if err == nil && (!inPlace(data) || m.table == nil && m.sparse == nil) {
+		err = ErrNotInPlace
+	}
+	if err != nil {
+		unmapFile(data)
+		return nil, err
+	}
+
+	return &MappedMatcher{m, data}, nil
+}
+
+// Close unmaps the file the Matcher was loaded from
+func (mm *MappedMatcher) Close() error {
+	if mm.data == nil {
+		return nil
+	}
+
+	err := unmapFile(mm.data)
+	mm.data = nil
+	mm.Matcher = nil
+
+	return err
+}
@@ -0,0 +1,28 @@
+// mmap_other.go: reading files into memory where they cannot be
+// memory mapped
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+//go:build !unix
+
+package ahocorasick
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads size bytes of f into memory
+func mapFile(f *os.File, size int) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// unmapFile undoes mapFile, which needs nothing doing
+func unmapFile(data []byte) error {
+	return nil
+}
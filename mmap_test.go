@@ -0,0 +1,84 @@
+// mmap_test.go: test suite for loading Matchers in place
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+// within returns true if p points into data
+func within(p unsafe.Pointer, data []byte) bool {
+	start := uintptr(unsafe.Pointer(unsafe.SliceData(data)))
+	return uintptr(p) >= start && uintptr(p) < start+uintptr(len(data))
+}
+
+func TestLoadInPlace(t *testing.T) {
+	for _, opts := range [][]Option{{WithDFA()}, {WithCompact(), WithCaseInsensitive()}, nil} {
+		m := NewStringMatcher(dictionary6, opts...)
+		data, _ := m.MarshalBinary()
+
+		l, err := Load(data)
+		assert(t, err == nil)
+		sameMatches(t, m, l, bytes2)
+
+		if l.table != nil {
+			assert(t, within(unsafe.Pointer(&l.table.next[0]), data))
+		}
+		if l.sparse != nil {
+			assert(t, within(unsafe.Pointer(&l.sparse.next[0]), data))
+			assert(t, within(unsafe.Pointer(&l.sparse.index[0]), data))
+		}
+	}
+}
+
+func TestLoadUnaligned(t *testing.T) {
+	m := NewStringMatcher(dictionary6, WithDFA())
+	data, _ := m.MarshalBinary()
+
+	unaligned := make([]byte, len(data)+1)[1:]
+	copy(unaligned, data)
+
+	l, err := Load(unaligned)
+	assert(t, err == nil)
+	assert(t, !within(unsafe.Pointer(&l.table.next[0]), unaligned))
+	sameMatches(t, m, l, bytes2)
+
+	_, err = Load(data[:len(data)-1])
+	assert(t, err != nil)
+}
+
+func TestOpen(t *testing.T) {
+	m := NewStringMatcher(dictionary6, WithDFA(), WithAllOccurrences())
+	data, _ := m.MarshalBinary()
+
+	name := filepath.Join(t.TempDir(), "matcher")
+	assert(t, os.WriteFile(name, data, 0o644) == nil)
+
+	mm, err := Open(name)
+	assert(t, err == nil)
+	sameMatches(t, m, mm.Matcher, bytes2)
+	assert(t, len(mm.Match(bytes2)) == len(m.Match(bytes2)))
+	assert(t, mm.Close() == nil)
+	assert(t, mm.Close() == nil)
+
+	_, err = Open(filepath.Join(t.TempDir(), "missing"))
+	assert(t, err != nil)
+
+	// The default automaton would be rebuilt in memory
+
+	data, _ = NewStringMatcher(dictionary6).MarshalBinary()
+	trie := filepath.Join(t.TempDir(), "trie")
+	assert(t, os.WriteFile(trie, data, 0o644) == nil)
+	_, err = Open(trie)
+	assert(t, err == ErrNotInPlace)
+
+	empty := filepath.Join(t.TempDir(), "empty")
+	assert(t, os.WriteFile(empty, nil, 0o644) == nil)
+	_, err = Open(empty)
+	assert(t, err == ErrFormat)
+}
@@ -0,0 +1,30 @@
+// mmap_unix.go: memory mapping files on systems that support it
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+//go:build unix
+
+package ahocorasick
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps size bytes of f into memory read only
+func mapFile(f *os.File, size int) ([]byte, error) {
+	if size == 0 {
+		return nil, nil
+	}
+
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// unmapFile undoes mapFile
+func unmapFile(data []byte) error {
+	if data == nil {
+		return nil
+	}
+
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,390 @@
+// pattern.go: dictionary entries with wildcards, where each byte of an
+// entry can be a set of bytes, as used in anti-virus signatures.
+//
+// Rather than putting every combination of bytes a pattern could match
+// into the trie, only the longest run of bytes in each pattern which
+// match few enough bytes, such as plain bytes or case-insensitive
+// letters, is put into it. Each string that run matches is an atom.
+// Wherever an atom is found the rest of its pattern is checked against
+// the input around it.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strconv"
+)
+
+// ByteSet is a set of bytes. The zero value is the empty set.
+type ByteSet [4]uint64
+
+// Add adds b to the set
+func (s *ByteSet) Add(b byte) {
+	s[b/64] |= 1 << (b % 64)
+}
+
+// AddRange adds the bytes from lo to hi inclusive to the set
+func (s *ByteSet) AddRange(lo, hi byte) {
+	for b := int(lo); b <= int(hi); b++ {
+		s.Add(byte(b))
+	}
+}
+
+// Contains returns true if b is in the set
+func (s *ByteSet) Contains(b byte) bool {
+	return s[b/64]&(1<<(b%64)) != 0
+}
+
+// Len returns the number of bytes in the set
+func (s *ByteSet) Len() int {
+	n := 0
+	for _, w := range s {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// bytes returns the bytes in the set in increasing order
+func (s *ByteSet) bytes() []byte {
+	var bs []byte
+	for i, w := range s {
+		for ; w != 0; w &= w - 1 {
+			bs = append(bs, byte(64*i+bits.TrailingZeros64(w)))
+		}
+	}
+
+	return bs
+}
+
+// Pattern is a dictionary entry in which each byte is matched by any
+// byte in a set
+type Pattern []ByteSet
+
+// ParsePattern parses a pattern in which
+//
+//	?       matches any byte
+//	[abc]   matches any of the bytes listed
+//	[a-z]   matches any byte in the range, which can be mixed with the
+//	        above, as in [a-z_]
+//	[^abc]  matches any byte not listed
+//	\xHH    matches the byte with the hexadecimal value HH
+//	\c      matches c when c is any other byte, such as ? or [
+//
+// and any other byte matches itself. The same escapes can be used in
+// sets.
+//
+// A PatternMatcher finds a pattern through its longest run of bytes
+// which each match at most 4 bytes, such as "abc" or [Aa][Bb]. A
+// pattern with no such byte, such as "??" or "[0-9][0-9]", is instead
+// checked at every offset of the input, which is much slower.
+func ParsePattern(s string) (Pattern, error) {
+	var p Pattern
+
+	for i := 0; i < len(s); {
+		var set ByteSet
+
+		switch s[i] {
+		case '?':
+			set.AddRange(0, 255)
+			i++
+
+		case '[':
+			var err error
+			set, i, err = parseSet(s, i+1)
+			if err != nil {
+				return nil, err
+			}
+
+		default:
+			b, next, err := parseByte(s, i)
+			if err != nil {
+				return nil, err
+			}
+			set.Add(b)
+			i = next
+		}
+
+		p = append(p, set)
+	}
+
+	return p, nil
+}
+
+// MustParsePattern is like ParsePattern but panics if s cannot be
+// parsed
+func MustParsePattern(s string) Pattern {
+	p, err := ParsePattern(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+// parseByte parses a byte, which may be escaped, at offset i in s and
+// returns it along with the offset after it
+func parseByte(s string, i int) (byte, int, error) {
+	if s[i] != '\\' {
+		return s[i], i + 1, nil
+	}
+
+	if i+1 == len(s) {
+		return 0, 0, fmt.Errorf("ahocorasick: trailing \\ in pattern %q", s)
+	}
+
+	if s[i+1] != 'x' {
+		return s[i+1], i + 2, nil
+	}
+
+	if i+4 > len(s) {
+		return 0, 0, fmt.Errorf("ahocorasick: short \\x escape in pattern %q", s)
+	}
+
+	b, err := strconv.ParseUint(s[i+2:i+4], 16, 8)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ahocorasick: bad \\x escape in pattern %q", s)
+	}
+
+	return byte(b), i + 4, nil
+}
+
+// parseSet parses the set which starts at offset i in s, just after the
+// [, and returns it along with the offset after the ]
+func parseSet(s string, i int) (ByteSet, int, error) {
+	var set ByteSet
+
+	negate := i < len(s) && s[i] == '^'
+	if negate {
+		i++
+	}
+
+	for first := true; ; first = false {
+		if i == len(s) {
+			return set, 0, fmt.Errorf("ahocorasick: missing ] in pattern %q", s)
+		}
+		if s[i] == ']' && !first {
+			i++
+			break
+		}
+
+		lo, next, err := parseByte(s, i)
+		if err != nil {
+			return set, 0, err
+		}
+		i = next
+
+		hi := lo
+		if i+1 < len(s) && s[i] == '-' && s[i+1] != ']' {
+			hi, i, err = parseByte(s, i+1)
+			if err != nil {
+				return set, 0, err
+			}
+			if hi < lo {
+				return set, 0, fmt.Errorf("ahocorasick: bad range in pattern %q", s)
+			}
+		}
+
+		set.AddRange(lo, hi)
+	}
+
+	if negate {
+		for j := range set {
+			set[j] = ^set[j]
+		}
+	}
+
+	return set, i, nil
+}
+
+// matches returns true if the pattern matches in at offset start
+func (p Pattern) matches(in []byte, start int) bool {
+	if start < 0 || start+len(p) > len(in) {
+		return false
+	}
+
+	for k := range p {
+		if !p[k].Contains(in[start+k]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Limits on the atoms of a pattern
+const (
+	maxAtomSet = 4  // The most bytes a byte of an atom can match
+	maxAtoms   = 16 // The most atoms a pattern has
+)
+
+// atoms returns the atoms of the pattern, which are every string
+// matched by its longest run of bytes that each match at most
+// maxAtomSet bytes, as long as there are at most maxAtoms of them. Of
+// runs of the same length the one with the fewest atoms is chosen. The
+// offset in the pattern just past the run is also returned. There are
+// no atoms if no byte of the pattern is in a run.
+func (p Pattern) atoms() ([][]byte, int) {
+	length, count, end := 0, 0, 0
+
+	for k := range p {
+		n := 1
+		for j := k; j < len(p); j++ {
+			l := p[j].Len()
+			if l == 0 || l > maxAtomSet || n*l > maxAtoms {
+				break
+			}
+			n *= l
+
+			if j+1-k > length || j+1-k == length && n < count {
+				length, count, end = j+1-k, n, j+1
+			}
+		}
+	}
+
+	if length == 0 {
+		return nil, 0
+	}
+
+	atoms := [][]byte{make([]byte, 0, length)}
+	for _, set := range p[end-length : end] {
+		var next [][]byte
+		for _, atom := range atoms {
+			for _, b := range set.bytes() {
+				next = append(next, append(atom[:len(atom):len(atom)], b))
+			}
+		}
+		atoms = next
+	}
+
+	return atoms, end
+}
+
+// PatternMatcher finds the occurrences of a dictionary of Patterns
+type PatternMatcher struct {
+	m *Matcher // Finds the atoms of the patterns
+
+	patterns []Pattern
+
+	owners []int // The pattern each atom in m is from
+
+	offsets []int // The offset in its pattern of the end of each
+	// pattern's atoms
+
+	always []int // The patterns which have no atom and are checked
+	// at every offset of the input
+}
+
+// NewPatternMatcher creates a PatternMatcher for a dictionary of
+// Patterns. The options are passed on to NewMatcher; of them only
+// WithCompact() and WithDFA() make sense here. Patterns with no byte
+// that matches at most 4 bytes, such as "??", are not put into the
+// automaton but checked at every offset of the input, which is slow.
+func NewPatternMatcher(patterns []Pattern, opts ...Option) *PatternMatcher {
+	pm := &PatternMatcher{
+		patterns: patterns,
+		offsets:  make([]int, len(patterns)),
+	}
+
+	var atoms [][]byte
+	for i, p := range patterns {
+		as, end := p.atoms()
+		if len(p) > 0 && len(as) == 0 {
+			pm.always = append(pm.always, i)
+		}
+
+		for _, atom := range as {
+			atoms = append(atoms, atom)
+			pm.owners = append(pm.owners, i)
+		}
+		pm.offsets[i] = end
+	}
+
+	pm.m = NewMatcher(atoms, opts...)
+
+	return pm
+}
+
+// FindAll returns every occurrence of the patterns in `in`, in the
+// order in which they end. Occurrences ending at the same offset are
+// ordered longest first. It is thread-safe.
+func (pm *PatternMatcher) FindAll(in []byte) []Match {
+	var hits []Match
+
+	pm.m.scan(in, func(a, end int) bool {
+		i := pm.owners[a]
+		start := end - pm.offsets[i]
+		if pm.patterns[i].matches(in, start) {
+			hits = append(hits, Match{i, start, start + len(pm.patterns[i])})
+		}
+		return true
+	})
+
+	for _, i := range pm.always {
+		for start := 0; start+len(pm.patterns[i]) <= len(in); start++ {
+			if pm.patterns[i].matches(in, start) {
+				hits = append(hits, Match{i, start, start + len(pm.patterns[i])})
+			}
+		}
+	}
+
+	sortByEnd(hits)
+
+	return hits
+}
+
+// sortByEnd puts occurrences found out of order in the order FindAll()
+// returns them: by the offset at which they end, longest first
+func sortByEnd(hits []Match) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].End != hits[j].End {
+			return hits[i].End < hits[j].End
+		}
+		return hits[i].Start < hits[j].Start
+	})
+}
+
+// Match returns the indexes of the patterns which occur in `in`, each
+// once, in the order in which their first occurrences end. It is
+// thread-safe.
+func (pm *PatternMatcher) Match(in []byte) []int {
+	return firstIndexes(pm.FindAll(in))
+}
+
+// firstIndexes returns the index of each dictionary entry found in
+// hits, once, in the order of their first occurrences
+func firstIndexes(hits []Match) []int {
+	var indexes []int
+
+	seen := make(map[int]bool)
+	for _, h := range hits {
+		if !seen[h.Pattern] {
+			seen[h.Pattern] = true
+			indexes = append(indexes, h.Pattern)
+		}
+	}
+
+	return indexes
+}
+
+// Contains returns true if any of the patterns occur in `in`
+func (pm *PatternMatcher) Contains(in []byte) bool {
+	found := false
+
+	pm.m.scan(in, func(a, end int) bool {
+		i := pm.owners[a]
+		found = pm.patterns[i].matches(in, end-pm.offsets[i])
+		return !found
+	})
+
+	for _, i := range pm.always {
+		for start := 0; !found && start+len(pm.patterns[i]) <= len(in); start++ {
+			found = pm.patterns[i].matches(in, start)
+		}
+	}
+
+	return found
+}
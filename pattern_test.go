@@ -0,0 +1,132 @@
+// pattern_test.go: test suite for wildcard patterns
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	p := MustParsePattern(`a?[b-d_][^x]\?\x41`)
+	assert(t, len(p) == 6)
+	assert(t, p[0].Len() == 1 && p[0].Contains('a'))
+	assert(t, p[1].Len() == 256)
+	assert(t, p[2].Len() == 4 && p[2].Contains('c') && p[2].Contains('_'))
+	assert(t, p[3].Len() == 255 && !p[3].Contains('x'))
+	assert(t, p[4].Len() == 1 && p[4].Contains('?'))
+	assert(t, p[5].Len() == 1 && p[5].Contains('A'))
+
+	p = MustParsePattern(`[]a-][\]]`)
+	assert(t, len(p) == 2)
+	assert(t, p[0].Len() == 3 && p[0].Contains(']') && p[0].Contains('-'))
+	assert(t, p[1].Len() == 1 && p[1].Contains(']'))
+
+	for _, s := range []string{`[abc`, `abc\`, `\x4`, `\xZZ`, `[z-a]`, `[`} {
+		_, err := ParsePattern(s)
+		assert(t, err != nil)
+	}
+}
+
+func TestPatternMatcher(t *testing.T) {
+	patterns := []Pattern{
+		MustParsePattern("ab?d"),
+		MustParsePattern("[Mm]ozilla"),
+		MustParsePattern("??x"),
+		MustParsePattern("b[0-9][0-9]"),
+		MustParsePattern(""),
+	}
+
+	for _, opts := range [][]Option{nil, {WithCompact()}, {WithDFA()}} {
+		pm := NewPatternMatcher(patterns, opts...)
+
+		hits := pm.FindAll([]byte("abcd abxd mozilla b12x ab"))
+		assert(t, len(hits) == 6)
+		assert(t, hits[0] == Match{0, 0, 4})
+		assert(t, hits[1] == Match{2, 5, 8})
+		assert(t, hits[2] == Match{0, 5, 9})
+		assert(t, hits[3] == Match{1, 10, 17})
+		assert(t, hits[4] == Match{3, 18, 21})
+		assert(t, hits[5] == Match{2, 19, 22})
+
+		indexes := pm.Match([]byte("abcd abxd mozilla b12x ab"))
+		assert(t, len(indexes) == 4)
+		assert(t, indexes[0] == 0)
+		assert(t, indexes[1] == 2)
+		assert(t, indexes[2] == 1)
+		assert(t, indexes[3] == 3)
+
+		assert(t, pm.Contains([]byte("xxMozillaxx")))
+		assert(t, pm.Contains([]byte("12x")))
+		assert(t, !pm.Contains([]byte("abd b1")))
+	}
+}
+
+func TestPatternMatcherSharedAtoms(t *testing.T) {
+	pm := NewPatternMatcher([]Pattern{
+		MustParsePattern("x?abc"),
+		MustParsePattern("abc?y"),
+		MustParsePattern("abc"),
+	})
+
+	hits := pm.FindAll([]byte("xxabczy"))
+	assert(t, len(hits) == 3)
+	assert(t, hits[0] == Match{0, 0, 5})
+	assert(t, hits[1] == Match{2, 2, 5})
+	assert(t, hits[2] == Match{1, 2, 7})
+}
+
+func TestPatternAtoms(t *testing.T) {
+	tests := []struct {
+		pattern string
+		atoms   []string
+		end     int
+	}{
+		{"ab?d", []string{"ab"}, 2},
+		{"[Mm][Zz]", []string{"MZ", "Mz", "mZ", "mz"}, 2},
+		{"?[Mm]ozilla", []string{"Mozilla", "mozilla"}, 8},
+		{"[0-9]x[0-9]", []string{"x"}, 2},
+		{"a[bc][de][fg][hi][jk]", nil, 5},
+		{"??", nil, 0},
+		{"", nil, 0},
+	}
+
+	for _, test := range tests {
+		atoms, end := MustParsePattern(test.pattern).atoms()
+		assert(t, end == test.end)
+
+		if test.atoms == nil {
+			continue
+		}
+		assert(t, len(atoms) == len(test.atoms))
+		for i := range atoms {
+			assert(t, string(atoms[i]) == test.atoms[i])
+		}
+	}
+
+	// Runs are cut short when they would have too many atoms
+
+	atoms, _ := MustParsePattern("a[bc][de][fg][hi][jk]").atoms()
+	assert(t, len(atoms) == maxAtoms)
+	assert(t, string(atoms[0]) == "abdfh")
+	assert(t, string(atoms[maxAtoms-1]) == "acegi")
+}
+
+func TestPatternMatcherClasses(t *testing.T) {
+	pm := NewPatternMatcher([]Pattern{
+		MustParsePattern("[Mm][Zz]"),
+		MustParsePattern("[Mm][Zz]?[Pp]"),
+	})
+	assert(t, len(pm.always) == 0)
+
+	hits := pm.FindAll([]byte("MZ mz mZxp xx"))
+	assert(t, len(hits) == 4)
+	assert(t, hits[0] == Match{0, 0, 2})
+	assert(t, hits[1] == Match{0, 3, 5})
+	assert(t, hits[2] == Match{0, 6, 8})
+	assert(t, hits[3] == Match{1, 6, 10})
+
+	assert(t, pm.Contains([]byte("xmz")))
+	assert(t, !pm.Contains([]byte("m z")))
+}
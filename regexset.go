@@ -0,0 +1,277 @@
+// regexset.go: running many regular expressions over the same input,
+// using the automaton to skip those which cannot match.
+//
+// Most regular expressions cannot match unless the input contains one
+// of a few literal strings, such as "GET" or "POST" for
+// ^(GET|POST) /admin. Those literals are worked out from the parsed
+// form of each expression and put into a Matcher, and only the
+// expressions whose literals are found are run.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+// maxLiterals is the most literals that are kept for a part of an
+// expression. Beyond that the part is treated as having none, as
+// checking so many is unlikely to rule the expression out.
+const maxLiterals = 16
+
+// RegexSet matches a set of regular expressions against the same
+// input, only running those which could match. Its methods are safe for
+// concurrent use.
+type RegexSet struct {
+	regexps []*regexp.Regexp
+
+	m *Matcher // Finds the literals of the expressions
+
+	owners []int // The expression each literal in m is from
+
+	always []int // The expressions with no literals, which are always
+	// run
+}
+
+// NewRegexSet compiles a set of regular expressions, with the syntax
+// used by regexp.Compile, into a RegexSet
+func NewRegexSet(exprs []string) (*RegexSet, error) {
+	s := &RegexSet{regexps: make([]*regexp.Regexp, len(exprs))}
+
+	var literals [][]byte
+	for i, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		s.regexps[i] = re
+
+		// This cannot fail as regexp.Compile has just parsed it
+
+		parsed, _ := syntax.Parse(expr, syntax.Perl)
+		_, required := requiredLiterals(parsed.Simplify())
+		if required == nil {
+			s.always = append(s.always, i)
+			continue
+		}
+
+		for _, l := range required {
+			literals = append(literals, []byte(l))
+			s.owners = append(s.owners, i)
+		}
+	}
+
+	// Literals which are case-insensitive in an expression are found
+	// whatever their case, so they are all matched that way. That
+	// only means some expressions are run when they need not be.
+
+	s.m = NewMatcher(literals, WithUnicodeFolding())
+
+	return s, nil
+}
+
+// MustNewRegexSet is like NewRegexSet but panics if any of the
+// expressions cannot be compiled
+func MustNewRegexSet(exprs []string) *RegexSet {
+	s, err := NewRegexSet(exprs)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// Regexp returns the compiled form of expression i
+func (s *RegexSet) Regexp(i int) *regexp.Regexp {
+	return s.regexps[i]
+}
+
+// Match returns the indexes of the expressions which match in, in
+// increasing order
+func (s *RegexSet) Match(in []byte) []int {
+	candidate := make([]bool, len(s.regexps))
+	for _, i := range s.always {
+		candidate[i] = true
+	}
+
+	s.m.scan(in, func(l, _ int) bool {
+		candidate[s.owners[l]] = true
+		return true
+	})
+
+	var hits []int
+	for i, c := range candidate {
+		if c && s.regexps[i].Match(in) {
+			hits = append(hits, i)
+		}
+	}
+
+	return hits
+}
+
+// requiredLiterals works out, for a parsed expression, the set of
+// strings it matches if that is small (exact), and a small set of
+// strings at least one of which is in every match (required). Either
+// is nil if there is no such set.
+func requiredLiterals(re *syntax.Regexp) (exact, required []string) {
+	// Invalid UTF-8 in the input matches utf8.RuneError in an
+	// expression, but the bytes of the rune in a literal would not
+	// match it
+
+	for _, r := range re.Rune {
+		if r == utf8.RuneError {
+			return nil, nil
+		}
+	}
+
+	switch re.Op {
+	case syntax.OpLiteral:
+		exact = []string{string(re.Rune)}
+
+	case syntax.OpCharClass:
+		n := 0
+		for i := 0; i < len(re.Rune); i += 2 {
+			n += int(re.Rune[i+1]-re.Rune[i]) + 1
+			if n > maxLiterals {
+				return nil, nil
+			}
+		}
+
+		for i := 0; i < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				exact = append(exact, string(r))
+			}
+		}
+
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary,
+		syntax.OpNoWordBoundary:
+		exact = []string{""}
+
+	case syntax.OpCapture:
+		return requiredLiterals(re.Sub[0])
+
+	case syntax.OpPlus:
+		_, required = requiredLiterals(re.Sub[0])
+		return nil, required
+
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			_, required = requiredLiterals(re.Sub[0])
+		}
+		return nil, required
+
+	case syntax.OpAlternate:
+		exact, required = []string{}, []string{}
+		for _, sub := range re.Sub {
+			e, r := requiredLiterals(sub)
+			exact = union(exact, e)
+			required = union(required, r)
+		}
+		return exact, usable(required)
+
+	case syntax.OpConcat:
+		return concatLiterals(re.Sub)
+
+	default:
+		return nil, nil
+	}
+
+	return exact, usable(exact)
+}
+
+// concatLiterals does the work of requiredLiterals() for the parts of
+// a concatenation. Runs of parts with exact sets are combined into
+// longer strings, and the best of those and of the required sets of
+// the parts is required.
+func concatLiterals(subs []*syntax.Regexp) (exact, required []string) {
+	exact = []string{""}
+	run := []string{""}
+
+	for _, sub := range subs {
+		e, r := requiredLiterals(sub)
+		required = better(required, r)
+
+		exact = product(exact, e)
+		run = product(run, e)
+		if run == nil {
+			run = []string{""}
+		} else {
+			required = better(required, usable(run))
+		}
+	}
+
+	return exact, better(required, usable(exact))
+}
+
+// union returns the strings in either a or b, or nil if either is nil
+// or there are too many
+func union(a, b []string) []string {
+	if a == nil || b == nil || len(a)+len(b) > maxLiterals {
+		return nil
+	}
+
+	return append(append([]string{}, a...), b...)
+}
+
+// product returns every string in a followed by one in b, or nil if
+// either is nil or there are too many
+func product(a, b []string) []string {
+	if a == nil || b == nil || len(a)*len(b) > maxLiterals {
+		return nil
+	}
+
+	var p []string
+	for _, x := range a {
+		for _, y := range b {
+			p = append(p, x+y)
+		}
+	}
+
+	return p
+}
+
+// usable returns the strings in a if they can be used as required
+// literals, which they cannot if one is empty
+func usable(a []string) []string {
+	for _, s := range a {
+		if s == "" {
+			return nil
+		}
+	}
+
+	return a
+}
+
+// better returns whichever of two sets of required literals rules out
+// more input: the one whose shortest literal is longer, or failing that
+// the one with fewer literals
+func better(a, b []string) []string {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+
+	if sa, sb := shortest(a), shortest(b); sb > sa || sb == sa && len(b) < len(a) {
+		return b
+	}
+
+	return a
+}
+
+// shortest returns the length in runes of the shortest string in a
+func shortest(a []string) int {
+	n := -1
+	for _, s := range a {
+		if l := utf8.RuneCountInString(s); n < 0 || l < n {
+			n = l
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,117 @@
+// regexset_test.go: test suite for RegexSet
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRequiredLiterals(t *testing.T) {
+	tests := []struct {
+		expr     string
+		required []string
+	}{
+		{`hello`, []string{"hello"}},
+		{`^(GET|POST) /admin`, []string{"GET /admin", "POST /admin"}},
+		{`(GET|POST)`, []string{"GET", "POST"}},
+		{`a[bc]d`, []string{"abd", "acd"}},
+		{`x+yz`, []string{"yz"}},
+		{`(abc)+`, []string{"abc"}},
+		{`a{2,}`, []string{"a"}},
+		{`\d+\.\d+`, []string{"."}},
+		{`[a-z]+@example\.com`, []string{"@example.com"}},
+		{`(?i)Mozilla`, []string{"MOZILLA"}},
+		{`.*`, nil},
+		{`a*`, nil},
+		{`(foo|b?)`, nil},
+		{`[a-z]+`, nil},
+		{`\x{FFFD}abc`, nil},
+	}
+
+	for _, test := range tests {
+		re, err := syntax.Parse(test.expr, syntax.Perl)
+		assert(t, err == nil)
+
+		_, required := requiredLiterals(re.Simplify())
+		sort.Strings(required)
+		assert(t, strings.Join(required, "|") == strings.Join(test.required, "|"))
+		assert(t, (required == nil) == (test.required == nil))
+	}
+}
+
+func TestRegexSet(t *testing.T) {
+	exprs := []string{
+		`Fire(fox|bird)`,
+		`\d{4}`,
+		`(?i)MOZILLA SUITE`,
+		`^Firefox is`,
+		`Netscape$`,
+		`[Pp]hoenix\s+Tech`,
+		`.`,
+		`^$`,
+	}
+	s := MustNewRegexSet(exprs)
+
+	hits := s.Match(bytes2)
+	assert(t, len(hits) == 6)
+	for _, i := range hits {
+		assert(t, regexp.MustCompile(exprs[i]).Match(bytes2))
+	}
+	for i, expr := range exprs {
+		assert(t, regexp.MustCompile(expr).Match(bytes2) == (sort.SearchInts(hits, i) < len(hits) && hits[sort.SearchInts(hits, i)] == i))
+	}
+
+	hits = s.Match(nil)
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == 7)
+
+	assert(t, s.Regexp(1).String() == `\d{4}`)
+
+	// The Kelvin sign folds to k, as it does in the expression
+
+	s = MustNewRegexSet([]string{`(?i)kelvin`, `kelvin`})
+	hits = s.Match([]byte("\u212Aelvin"))
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == 0)
+
+	_, err := NewRegexSet([]string{`a(`})
+	assert(t, err != nil)
+}
+
+var regexSetExprs = func() []string {
+	var exprs []string
+	for _, s := range dictionary6 {
+		exprs = append(exprs, `\b`+regexp.QuoteMeta(s)+`\s+[a-z]+`)
+	}
+	return exprs
+}()
+
+var precomputedRegexSet = MustNewRegexSet(regexSetExprs)
+
+var precomputedRegexps = func() []*regexp.Regexp {
+	var res []*regexp.Regexp
+	for _, expr := range regexSetExprs {
+		res = append(res, regexp.MustCompile(expr))
+	}
+	return res
+}()
+
+func BenchmarkRegexSetMany(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		precomputedRegexSet.Match(bytes)
+	}
+}
+
+func BenchmarkRegexpEachMany(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, re := range precomputedRegexps {
+			re.Match(bytes)
+		}
+	}
+}
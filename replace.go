@@ -0,0 +1,36 @@
+// replace.go: replacing dictionary entries found in the input in a
+// single pass over it.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+// Replace returns a copy of in with each of the occurrences found by
+// FindAllNonOverlapping() replaced by replacements[i], where i is the
+// index of the occurrence's entry in the dictionary. There must be a
+// replacement for every entry in the dictionary.
+//
+// The choice between overlapping occurrences depends on the MatchKind
+// the Matcher was created with: with "he" and "hers" in the dictionary
+// LeftmostLongest replaces all of "hers" while Standard and
+// LeftmostFirst only replace the "he".
+func (m *Matcher) Replace(in []byte, replacements [][]byte) []byte {
+	return m.ReplaceFunc(in, func(h Match) []byte {
+		return replacements[h.Pattern]
+	})
+}
+
+// ReplaceFunc is like Replace() but each occurrence is replaced by the
+// result of calling fn with it
+func (m *Matcher) ReplaceFunc(in []byte, fn func(Match) []byte) []byte {
+	out := make([]byte, 0, len(in))
+
+	last := 0
+	for _, h := range m.FindAllNonOverlapping(in) {
+		out = append(out, in[last:h.Start]...)
+		out = append(out, fn(h)...)
+		last = h.End
+	}
+
+	return append(out, in[last:]...)
+}
@@ -0,0 +1,39 @@
+// replace_test.go: test suite for Replace
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplace(t *testing.T) {
+	replacements := [][]byte{[]byte("HE"), []byte("HERS"), []byte("SHE")}
+
+	m := NewStringMatcher([]string{"he", "hers", "she"})
+	out := m.Replace([]byte("hers ushers, he said"), replacements)
+	assert(t, string(out) == "HErs uSHErs, HE said")
+
+	m = NewStringMatcher([]string{"he", "hers", "she"}, WithMatchKind(LeftmostLongest))
+	out = m.Replace([]byte("hers ushers, he said"), replacements)
+	assert(t, string(out) == "HERS uSHErs, HE said")
+
+	out = m.Replace([]byte("nothing to see"), replacements)
+	assert(t, string(out) == "nothing to see")
+
+	out = m.Replace([]byte(""), replacements)
+	assert(t, len(out) == 0)
+}
+
+func TestReplaceFunc(t *testing.T) {
+	m := NewStringMatcher([]string{"password", "secret"}, WithCaseInsensitive())
+
+	in := []byte("user=admin Password=hunter2 SECRET=xyz")
+	out := m.ReplaceFunc(in, func(h Match) []byte {
+		return []byte(strings.Repeat("*", h.End-h.Start))
+	})
+	assert(t, string(out) == "user=admin ********=hunter2 ******=xyz")
+	assert(t, string(in) == "user=admin Password=hunter2 SECRET=xyz")
+}
@@ -0,0 +1,190 @@
+// replacer.go: replacing dictionary entries in a stream, such as a
+// proxied HTTP body, holding back only as much of it as could be part
+// of an occurrence.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+var (
+	// ErrShortDst is returned by Replacer.Transform when dst is too
+	// short for the output. It means the same as the error of the same
+	// name in golang.org/x/text/transform.
+	ErrShortDst = errors.New("ahocorasick: short destination buffer")
+
+	// ErrShortSrc is returned by Replacer.Transform when more input is
+	// needed before the rest of src can be replaced. It means the same
+	// as the error of the same name in golang.org/x/text/transform.
+	ErrShortSrc = errors.New("ahocorasick: short source buffer")
+)
+
+// Replacer replaces the occurrences of a Matcher's dictionary entries
+// in a stream as Matcher.Replace() does in a single blice. Input is
+// held back only while it could still be part of an occurrence, so no
+// more than the length of the longest entry is buffered.
+//
+// A Replacer is used either as an io.WriteCloser, writing its output to
+// an underlying io.Writer, or through its Transform method. It is not
+// safe for concurrent use.
+type Replacer struct {
+	m  *Matcher
+	fn func(Match) []byte // Gives the replacement for an occurrence
+
+	w   io.Writer // Where the output of Write goes
+	err error     // The first error returned by w
+
+	buf []byte // Input that has not been replaced yet
+	out []byte // Output that has not been written yet
+
+	prev []byte // The end of the input which has been replaced, used
+	// to check word boundaries
+
+	offset int // Offset in the stream of the next byte to replace
+}
+
+// NewReplacer creates a Replacer which writes to w with occurrences of
+// entry i in the dictionary replaced by replacements[i]. There must be
+// a replacement for every entry in the dictionary.
+func (m *Matcher) NewReplacer(w io.Writer, replacements [][]byte) *Replacer {
+	return m.NewReplacerFunc(w, func(h Match) []byte {
+		return replacements[h.Pattern]
+	})
+}
+
+// NewReplacerFunc creates a Replacer which writes to w with
+// occurrences replaced by the result of calling fn with them. The
+// offsets in the Match given to fn are relative to the start of the
+// stream. w may be nil if only Transform is going to be used.
+func (m *Matcher) NewReplacerFunc(w io.Writer, fn func(Match) []byte) *Replacer {
+	return &Replacer{m: m, fn: fn, w: w}
+}
+
+// replace appends to dst the replacement of as much of src as can be
+// replaced without seeing what follows it, or all of src if atEOF is
+// true, and returns the result along with the number of bytes of src
+// consumed.
+func (r *Replacer) replace(dst, src []byte, atEOF bool) ([]byte, int) {
+
+	// An occurrence which starts before safe must end within src, so
+	// nothing after src can change whether it is chosen. When only
+	// whole words are matched the rune after it must be in src too.
+
+	safe := len(src)
+	if !atEOF {
+		if span := r.m.span(); span > 1 {
+			safe -= span - 1
+		}
+		if r.m.isWord != nil {
+			safe -= utf8.UTFMax
+		}
+	}
+
+	last := 0
+	for _, h := range r.m.choose(r.m.findAll(r.prev, src)) {
+		if h.Start >= safe {
+			break
+		}
+
+		dst = append(dst, src[last:h.Start]...)
+		dst = append(dst, r.fn(Match{h.Pattern, r.offset + h.Start, r.offset + h.End})...)
+		last = h.End
+	}
+
+	if safe > last {
+		dst = append(dst, src[last:safe]...)
+		last = safe
+	}
+
+	r.offset += last
+
+	if r.m.isWord != nil {
+		r.prev = append(r.prev, src[max(0, last-utf8.UTFMax):last]...)
+		r.prev = r.prev[:copy(r.prev, r.prev[max(0, len(r.prev)-utf8.UTFMax):])]
+	}
+
+	return dst, last
+}
+
+// Write replaces occurrences in p and writes the result to the
+// underlying io.Writer, apart from the end of p which is held back
+// until more input is written or the Replacer is closed. Once writing
+// to the underlying io.Writer fails every Write returns that error.
+func (r *Replacer) Write(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	r.buf = append(r.buf, p...)
+	r.flush(false)
+
+	return len(p), r.err
+}
+
+// Close replaces occurrences in any input which has been held back and
+// writes the result. It does not close the underlying io.Writer.
+func (r *Replacer) Close() error {
+	if r.err == nil {
+		r.flush(true)
+	}
+
+	return r.err
+}
+
+// flush replaces what it can of the buffered input and writes it
+func (r *Replacer) flush(atEOF bool) {
+	var n int
+
+	r.out, n = r.replace(r.out[:0], r.buf, atEOF)
+	r.buf = r.buf[:copy(r.buf, r.buf[n:])]
+
+	if len(r.out) > 0 {
+		_, r.err = r.w.Write(r.out)
+	}
+}
+
+// Transform replaces occurrences in src, writing the result to dst,
+// with the same semantics as the Transform method of a
+// golang.org/x/text/transform.Transformer. As this package does not
+// depend on that one it returns its own ErrShortDst and ErrShortSrc,
+// so an adapter mapping those errors is needed to use a Replacer with
+// transform.Reader or transform.Writer.
+func (r *Replacer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for {
+
+		// Output left over from a previous call which did not fit in
+		// dst has to go first
+
+		n := copy(dst[nDst:], r.out)
+		nDst += n
+		r.out = r.out[n:]
+		if len(r.out) > 0 {
+			return nDst, nSrc, ErrShortDst
+		}
+
+		if nSrc == len(src) {
+			return nDst, nSrc, nil
+		}
+
+		r.out, n = r.replace(r.out[:0], src[nSrc:], atEOF)
+		nSrc += n
+		if n == 0 {
+			return nDst, nSrc, ErrShortSrc
+		}
+	}
+}
+
+// Reset discards any buffered input and output so that the Replacer
+// can be used for a new stream, with offsets starting from zero again
+func (r *Replacer) Reset() {
+	r.err = nil
+	r.buf = r.buf[:0]
+	r.out = r.out[:0]
+	r.prev = r.prev[:0]
+	r.offset = 0
+}
@@ -0,0 +1,115 @@
+// replacer_test.go: test suite for Replacer
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReplacerSameAsReplace(t *testing.T) {
+	d := []string{"Firefox", "Fire", "fox", "Mozilla", "Phoenix", "Mozilla Suite", "a"}
+	replacements := make([][]byte, len(d))
+	for i := range d {
+		replacements[i] = []byte(strings.Repeat("#", i))
+	}
+
+	for _, k := range []MatchKind{Standard, LeftmostFirst, LeftmostLongest} {
+		m := NewStringMatcher(d, WithMatchKind(k))
+		want := string(m.Replace(bytes2, replacements))
+
+		for _, size := range []int{1, 3, 13, 100, len(bytes2)} {
+			var out strings.Builder
+			r := m.NewReplacer(&out, replacements)
+
+			for i := 0; i < len(bytes2); i += size {
+				j := i + size
+				if j > len(bytes2) {
+					j = len(bytes2)
+				}
+				n, err := r.Write(bytes2[i:j])
+				assert(t, n == j-i)
+				assert(t, err == nil)
+			}
+			assert(t, r.Close() == nil)
+
+			assert(t, out.String() == want)
+		}
+	}
+}
+
+func TestReplacerOffsets(t *testing.T) {
+	m := NewStringMatcher([]string{"secret"})
+
+	var out strings.Builder
+	var hits []Match
+	r := m.NewReplacerFunc(&out, func(h Match) []byte {
+		hits = append(hits, h)
+		return []byte("******")
+	})
+
+	io.Copy(r, strings.NewReader("a secret and another secret"))
+	r.Close()
+	assert(t, out.String() == "a ****** and another ******")
+	assert(t, len(hits) == 2)
+	assert(t, hits[0] == Match{0, 2, 8})
+	assert(t, hits[1] == Match{0, 21, 27})
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("failed")
+}
+
+func TestReplacerWriteError(t *testing.T) {
+	m := NewStringMatcher([]string{"x"})
+	r := m.NewReplacer(failingWriter{}, [][]byte{[]byte("y")})
+
+	_, err := r.Write([]byte("abc"))
+	assert(t, err != nil)
+	_, err = r.Write([]byte("abc"))
+	assert(t, err != nil)
+	assert(t, r.Close() != nil)
+}
+
+func TestReplacerTransform(t *testing.T) {
+	m := NewStringMatcher([]string{"hers", "he"}, WithMatchKind(LeftmostLongest))
+	r := m.NewReplacer(nil, [][]byte{[]byte("HERS!"), []byte("HE!")})
+
+	dst := make([]byte, 4)
+
+	nDst, nSrc, err := r.Transform(dst, []byte("he"), false)
+	assert(t, nDst == 0)
+	assert(t, nSrc == 0)
+	assert(t, err == ErrShortSrc)
+
+	nDst, nSrc, err = r.Transform(dst, []byte("hers"), false)
+	assert(t, nDst == 4)
+	assert(t, nSrc == 4)
+	assert(t, err == ErrShortDst)
+	assert(t, string(dst) == "HERS")
+
+	nDst, nSrc, err = r.Transform(dst, []byte(" he"), true)
+	assert(t, nDst == 4)
+	assert(t, nSrc == 3)
+	assert(t, err == ErrShortDst)
+	assert(t, string(dst) == "! HE")
+
+	nDst, nSrc, err = r.Transform(dst, nil, true)
+	assert(t, nDst == 1)
+	assert(t, nSrc == 0)
+	assert(t, err == nil)
+	assert(t, string(dst[:1]) == "!")
+
+	r.Reset()
+	nDst, nSrc, err = r.Transform(dst, []byte("abc"), true)
+	assert(t, nDst == 3)
+	assert(t, nSrc == 3)
+	assert(t, err == nil)
+	assert(t, string(dst[:3]) == "abc")
+}
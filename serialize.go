@@ -0,0 +1,530 @@
+// serialize.go: saving a compiled Matcher and loading it again, so that
+// the automaton for a large dictionary can be built once and shipped
+// rather than built every time a process starts.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"unsafe"
+)
+
+var (
+	// ErrFormat is returned by Matcher.UnmarshalBinary when the data
+	// is not a serialized Matcher or is truncated
+	ErrFormat = errors.New("ahocorasick: invalid serialized Matcher")
+
+	// ErrVersion is returned by Matcher.UnmarshalBinary when the data
+	// was written in a format this version of the package cannot read
+	ErrVersion = errors.New("ahocorasick: unsupported serialization version")
+
+	// ErrChecksum is returned by Matcher.UnmarshalBinary when the data
+	// has been corrupted
+	ErrChecksum = errors.New("ahocorasick: serialized Matcher checksum mismatch")
+
+	// ErrWordBoundaries is returned by Matcher.MarshalBinary when the
+	// Matcher was created WithWordBoundaries(), as the function which
+	// decides what is a word cannot be serialized
+	ErrWordBoundaries = errors.New("ahocorasick: cannot serialize a Matcher with word boundaries")
+
+	// ErrShared is returned by Matcher.MarshalBinary when the Matcher
+	// was built by Builder.Build and shares its automaton with another
+	// Matcher. Builder.Rebuild builds one which can be serialized.
+	ErrShared = errors.New("ahocorasick: cannot serialize a Matcher which shares its automaton")
+)
+
+// The serialized form of a Matcher is a header followed by the arrays
+// of its automaton and a checksum. Every value is a little endian
+// uint32 and every array is preceded by its length and padded to a
+// multiple of four bytes, so that the arrays of uint32s are aligned
+// and can be used in place.
+
+const (
+	serialMagic   = 0x636f6861 // "ahoc" as a little endian uint32
+	serialVersion = 1
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Flags stored in the header for the options a Matcher was created
+// with
+const (
+	serialAll = 1 << iota
+	serialFold
+	serialUnicode
+)
+
+// The automaton stored after the header
+const (
+	serialTrie = iota
+	serialCompact
+	serialDFA
+)
+
+// encoder appends values in the serialized form to buf
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) u32(v uint32) {
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *encoder) u32s(vs []uint32) {
+	e.u32(uint32(len(vs)))
+	for _, v := range vs {
+		e.u32(v)
+	}
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.u32(uint32(len(b)))
+	e.buf = append(e.buf, b...)
+	for len(e.buf)%4 != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+// decoder reads values in the serialized form from data. Once the data
+// runs out err is set and every value read is zero or empty.
+type decoder struct {
+	data []byte
+	err  error
+
+	inPlace bool // true if arrays are returned as slices of data
+	// rather than copied out of it
+}
+
+func (d *decoder) u32() uint32 {
+	if len(d.data) < 4 {
+		d.err = ErrFormat
+		d.data = nil
+		return 0
+	}
+
+	v := binary.LittleEndian.Uint32(d.data)
+	d.data = d.data[4:]
+	return v
+}
+
+func (d *decoder) u32s() []uint32 {
+	n := int(d.u32())
+	if n > len(d.data)/4 {
+		d.err = ErrFormat
+		d.data = nil
+		return nil
+	}
+
+	var vs []uint32
+	if d.inPlace && n > 0 {
+		vs = unsafe.Slice((*uint32)(unsafe.Pointer(&d.data[0])), n)
+	} else {
+		vs = make([]uint32, n)
+		for i := range vs {
+			vs[i] = binary.LittleEndian.Uint32(d.data[4*i:])
+		}
+	}
+	d.data = d.data[4*n:]
+	return vs
+}
+
+func (d *decoder) bytes() []byte {
+	n := int(d.u32())
+	padded := (n + 3) &^ 3
+	if n > len(d.data) || padded > len(d.data) {
+		d.err = ErrFormat
+		d.data = nil
+		return nil
+	}
+
+	var b []byte
+	if d.inPlace {
+		b = d.data[:n:n]
+	} else {
+		b = make([]byte, n)
+		copy(b, d.data)
+	}
+	d.data = d.data[padded:]
+	return b
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The result holds
+// the compiled automaton along with the options the Matcher was
+// created with, so that UnmarshalBinary can recreate it without the
+// cost of building it. It returns ErrWordBoundaries if the Matcher was
+// created WithWordBoundaries(), as the loaded Matcher would find
+// occurrences anywhere, and ErrShared if it was built by Builder.Build.
+func (m *Matcher) MarshalBinary() ([]byte, error) {
+	if m.isWord != nil {
+		return nil, ErrWordBoundaries
+	}
+	if m.base != nil {
+		return nil, ErrShared
+	}
+
+	e := &encoder{}
+
+	var flags uint32
+	if m.all {
+		flags |= serialAll
+	}
+	if m.fold {
+		flags |= serialFold
+	}
+	if m.unicode {
+		flags |= serialUnicode
+	}
+
+	e.u32(serialMagic)
+	e.u32(serialVersion)
+	e.u32(flags)
+	e.u32(uint32(m.kind))
+	e.u32(uint32(m.longest))
+
+	lens := make([]uint32, len(m.lens))
+	for i, l := range m.lens {
+		lens[i] = uint32(l)
+	}
+	e.u32s(lens)
+
+	dups := make([]uint32, len(m.dups))
+	for i, j := range m.dups {
+		dups[i] = uint32(j)
+	}
+	e.u32s(dups)
+
+	switch {
+	case m.table != nil:
+		e.u32(serialDFA)
+		m.table.marshal(e)
+	case m.sparse != nil:
+		e.u32(serialCompact)
+		m.sparse.marshal(e)
+	default:
+		e.u32(serialTrie)
+		m.marshalTrie(e)
+	}
+
+	e.u32(crc32.Checksum(e.buf, castagnoli))
+
+	return e.buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, turning a new
+// Matcher into the one serialized by MarshalBinary in data:
+//
+//	m := new(ahocorasick.Matcher)
+//	err := m.UnmarshalBinary(data)
+//
+// The Matcher must not be used if an error is returned. The data is
+// checked for corruption, but the automaton in it is trusted, so it
+// should only be loaded from a trusted source.
+func (m *Matcher) UnmarshalBinary(data []byte) error {
+	return m.unmarshal(data, false)
+}
+
+// unmarshal does the work of UnmarshalBinary. If inPlace is true the
+// arrays of the automaton are used where they are in data.
+func (m *Matcher) unmarshal(data []byte, inPlace bool) error {
+	if len(data) < 8 {
+		return ErrFormat
+	}
+
+	d := &decoder{data: data[:len(data)-4], inPlace: inPlace}
+	if d.u32() != serialMagic {
+		return ErrFormat
+	}
+
+	if d.u32() != serialVersion {
+		return ErrVersion
+	}
+
+	sum := binary.LittleEndian.Uint32(data[len(data)-4:])
+	if sum != crc32.Checksum(data[:len(data)-4], castagnoli) {
+		return ErrChecksum
+	}
+
+	flags := d.u32()
+	m.all = flags&serialAll != 0
+	m.fold = flags&serialFold != 0
+	m.unicode = flags&serialUnicode != 0
+	m.kind = MatchKind(d.u32())
+	m.longest = int(d.u32())
+
+	lens := d.u32s()
+	m.lens = make([]int, len(lens))
+	for i, l := range lens {
+		m.lens[i] = int(l)
+	}
+	m.seen = make([]uint64, len(lens))
+
+	dups := d.u32s()
+	if len(dups) != 0 && len(dups) != len(lens) {
+		return ErrFormat
+	}
+
+	// Each entry is followed by a later one, so that following
+	// them always comes to an end
+
+	if len(dups) > 0 {
+		m.dups = make([]int, len(dups))
+	}
+	for i, j := range dups {
+		m.dups[i] = int(int32(j))
+		if m.dups[i] != -1 && (m.dups[i] <= i || m.dups[i] >= len(dups)) {
+			return ErrFormat
+		}
+	}
+
+	var ok bool
+	switch d.u32() {
+	case serialDFA:
+		m.dfa = true
+		m.table, ok = unmarshalDFA(d, len(lens))
+	case serialCompact:
+		m.compact = true
+		m.sparse, ok = unmarshalCompact(d, len(lens))
+		if ok {
+			m.sparse.fold = m.fold
+		}
+	case serialTrie:
+		ok = m.unmarshalTrie(d, len(lens))
+	}
+
+	if !ok || d.err != nil || len(d.data) != 0 {
+		return ErrFormat
+	}
+
+	return nil
+}
+
+// marshalTrie writes the trie as arrays of node ids, using 0 (the
+// root, which is nobody's child) for a missing child. The fails slices
+// are not written as they are quick to work out again.
+func (m *Matcher) marshalTrie(e *encoder) {
+	index := make([]uint32, len(m.trie))
+	child := make([]uint32, len(m.trie)*m.stride)
+	fail := make([]uint32, len(m.trie))
+	suffix := make([]uint32, len(m.trie))
+
+	for i := range m.trie {
+		n := &m.trie[i]
+
+		index[i] = ^uint32(0)
+		if n.output {
+			index[i] = uint32(n.index)
+		}
+
+		for c, f := range n.child {
+			if f != nil {
+				child[i*m.stride+c] = f.id
+			}
+		}
+
+		if !n.root {
+			fail[i] = n.fail.id
+			suffix[i] = n.suffix.id
+		}
+	}
+
+	e.bytes(m.classes[:])
+	e.u32(uint32(m.stride))
+	e.u32s(index)
+	e.u32s(child)
+	e.u32s(fail)
+	e.u32s(suffix)
+}
+
+// unmarshalTrie rebuilds the trie written by marshalTrie for a
+// dictionary of size entries
+func (m *Matcher) unmarshalTrie(d *decoder, size int) bool {
+	classes := d.bytes()
+	m.stride = int(d.u32())
+	index := d.u32s()
+	child := d.u32s()
+	fail := d.u32s()
+	suffix := d.u32s()
+
+	nodes := len(index)
+	if d.err != nil || len(classes) != len(m.classes) || nodes == 0 ||
+		m.stride == 0 || m.stride > len(m.classes) ||
+		len(child) != nodes*m.stride || len(fail) != nodes || len(suffix) != nodes {
+		return false
+	}
+
+	copy(m.classes[:], classes)
+	for _, c := range m.classes {
+		if int(c) >= m.stride {
+			return false
+		}
+	}
+
+	m.trie = make([]node, nodes)
+	m.edges = make([]*node, 2*m.stride*nodes)
+	for range m.trie {
+		m.getFreeNode()
+	}
+	m.edges = nil
+
+	for i := range m.trie {
+		n := &m.trie[i]
+
+		if index[i] != ^uint32(0) {
+			if int(index[i]) >= size {
+				return false
+			}
+			n.output = true
+			n.index = int(index[i])
+		}
+
+		for c := range n.child {
+			if f := child[i*m.stride+c]; f != 0 {
+				if int(f) >= nodes {
+					return false
+				}
+				n.child[c] = &m.trie[f]
+			}
+		}
+
+		if !n.root {
+			if int(fail[i]) >= nodes || int(suffix[i]) >= nodes {
+				return false
+			}
+			n.fail = &m.trie[fail[i]]
+			n.suffix = &m.trie[suffix[i]]
+		}
+	}
+
+	// Every node along the suffix pointers is output when matching
+
+	for i := range m.trie {
+		if s := m.trie[i].suffix; s != nil && !s.root && !s.output {
+			return false
+		}
+	}
+
+	m.buildFails()
+
+	return true
+}
+
+func (c *compact) marshal(e *encoder) {
+	index := make([]uint32, len(c.index))
+	for i, v := range c.index {
+		index[i] = uint32(v)
+	}
+
+	e.u32s(c.first)
+	e.bytes(c.labels)
+	e.u32s(c.next)
+	e.u32s(c.root[:])
+	e.u32s(c.fail)
+	e.u32s(c.suffix)
+	e.u32s(index)
+}
+
+// unmarshalCompact reads the compact automaton written by
+// compact.marshal for a dictionary of size entries
+func unmarshalCompact(d *decoder, size int) (*compact, bool) {
+	c := &compact{
+		first:  d.u32s(),
+		labels: d.bytes(),
+		next:   d.u32s(),
+	}
+	root := d.u32s()
+	c.fail = d.u32s()
+	c.suffix = d.u32s()
+	index := d.u32s()
+
+	states := len(index)
+	if d.err != nil || states == 0 || len(c.first) != states+1 ||
+		len(root) != len(c.root) || len(c.fail) != states || len(c.suffix) != states ||
+		len(c.next) != len(c.labels) || c.first[0] != 0 || int(c.first[states]) != len(c.labels) {
+		return nil, false
+	}
+
+	copy(c.root[:], root)
+
+	// The indexes were written as uint32s with the same bits
+
+	c.index = unsafe.Slice((*int32)(unsafe.Pointer(unsafe.SliceData(index))), states)
+	for _, i := range c.index {
+		if i < -1 || int(i) >= size {
+			return nil, false
+		}
+	}
+
+	for s := 0; s < states; s++ {
+		if c.first[s] > c.first[s+1] || int(c.fail[s]) >= states || int(c.suffix[s]) >= states {
+			return nil, false
+		}
+	}
+
+	for _, ts := range [][]uint32{c.next, c.root[:]} {
+		for _, t := range ts {
+			if int(t) >= states {
+				return nil, false
+			}
+		}
+	}
+
+	return c, true
+}
+
+func (d *dfa) marshal(e *encoder) {
+	e.bytes(d.classes[:])
+	e.u32(d.stride)
+	e.u32s(d.next)
+	e.u32s(d.first)
+	e.u32s(d.out)
+}
+
+// unmarshalDFA reads the dfa written by dfa.marshal for a dictionary
+// of size entries
+func unmarshalDFA(dec *decoder, size int) (*dfa, bool) {
+	d := &dfa{}
+	classes := dec.bytes()
+	d.stride = dec.u32()
+	d.next = dec.u32s()
+	d.first = dec.u32s()
+	d.out = dec.u32s()
+
+	if dec.err != nil || len(classes) != len(d.classes) || d.stride == 0 ||
+		int(d.stride) > len(d.classes) || len(d.first) < 2 || d.first[0] != 0 ||
+		len(d.next) != (len(d.first)-1)*int(d.stride) ||
+		int(d.first[len(d.first)-1]) != len(d.out) {
+		return nil, false
+	}
+
+	copy(d.classes[:], classes)
+	for _, c := range d.classes {
+		if uint32(c) >= d.stride {
+			return nil, false
+		}
+	}
+
+	for i := 1; i < len(d.first); i++ {
+		if d.first[i-1] > d.first[i] {
+			return nil, false
+		}
+	}
+
+	for _, index := range d.out {
+		if int(index) >= size {
+			return nil, false
+		}
+	}
+
+	for _, t := range d.next {
+		s := t &^ dfaOutput
+		if int(s) >= len(d.next) || s%d.stride != 0 {
+			return nil, false
+		}
+	}
+
+	return d, true
+}
@@ -0,0 +1,101 @@
+// serialize_test.go: test suite for saving and loading Matchers
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"testing"
+)
+
+// roundTrip marshals m and unmarshals the result into a new Matcher
+func roundTrip(t *testing.T, m *Matcher) *Matcher {
+	data, err := m.MarshalBinary()
+	assert(t, err == nil)
+
+	l := new(Matcher)
+	assert(t, l.UnmarshalBinary(data) == nil)
+
+	return l
+}
+
+func TestSerializeSameAsBuilt(t *testing.T) {
+	dictionaries := [][]string{
+		dictionary, dictionary4, dictionary6,
+		{"a", "ab", "bc", "bca", "c", "caa"},
+		{"", "he", "she", "his", "hers"},
+		{"he", "she", "he", "He", "hers", "he"},
+		{},
+	}
+	inputs := [][]byte{bytes, bytes2, []byte("abccab"), []byte("ushers Superman"), []byte("")}
+
+	for _, d := range dictionaries {
+		for _, opts := range [][]Option{nil, {WithCaseInsensitive()}, {WithUnicodeFolding()}, {WithAllOccurrences()}} {
+			for _, automaton := range []Option{WithCompact(), WithDFA(), func(*Matcher) {}} {
+				m := NewStringMatcher(d, append(opts, automaton)...)
+				l := roundTrip(t, m)
+
+				assert(t, (l.table != nil) == (m.table != nil))
+				assert(t, (l.sparse != nil) == (m.sparse != nil))
+
+				for _, in := range inputs {
+					sameMatches(t, m, l, in)
+					assert(t, len(m.Match(in)) == len(l.Match(in)))
+				}
+			}
+		}
+	}
+}
+
+func TestSerializeOptions(t *testing.T) {
+	m := NewStringMatcher([]string{"he", "hers"}, WithCaseInsensitive(), WithMatchKind(LeftmostLongest))
+	l := roundTrip(t, m)
+
+	hits := l.FindAllNonOverlapping([]byte("HERS"))
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == Match{1, 0, 4})
+}
+
+func TestSerializeErrors(t *testing.T) {
+	m := NewStringMatcher(dictionary, WithDFA())
+	data, _ := m.MarshalBinary()
+
+	l := new(Matcher)
+	assert(t, l.UnmarshalBinary(nil) == ErrFormat)
+	assert(t, l.UnmarshalBinary([]byte("not a Matcher at all")) == ErrFormat)
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)/2] ^= 1
+	assert(t, l.UnmarshalBinary(corrupt) == ErrChecksum)
+
+	future := append([]byte(nil), data...)
+	future[4] = serialVersion + 1
+	assert(t, l.UnmarshalBinary(future) == ErrVersion)
+
+	assert(t, l.UnmarshalBinary(data[:len(data)-8]) != nil)
+
+	// Word boundaries would be lost
+
+	w := NewStringMatcher([]string{"cat"}, WithWordBoundaries(IsASCIIWord))
+	data, err := w.MarshalBinary()
+	assert(t, err == ErrWordBoundaries)
+	assert(t, data == nil)
+}
+
+func BenchmarkUnmarshalLarge(b *testing.B) {
+	data, _ := precomputed6.MarshalBinary()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		new(Matcher).UnmarshalBinary(data)
+	}
+}
+
+func BenchmarkUnmarshalLargeDFA(b *testing.B) {
+	data, _ := precomputedDFA6.MarshalBinary()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		new(Matcher).UnmarshalBinary(data)
+	}
+}
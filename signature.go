@@ -0,0 +1,510 @@
+// signature.go: YARA style hex signatures, which are Patterns separated
+// by gaps of a bounded number of bytes, such as { 4D 5A [2-4] 50 45 }.
+//
+// As with Patterns atoms are taken from each signature and put into
+// the trie, in this case from the part of the signature with the
+// longest atoms. Wherever an atom is found the part it came from is
+// checked, then the parts on either side of it. Where those parts can
+// go is worked out once per input with a pass over it for each part,
+// rather than by trying each length of the gaps, which could take time
+// exponential in the number of gaps.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Gap is a run of any bytes between two parts of a Signature
+type Gap struct {
+	Min int // The fewest bytes in the gap
+	Max int // The most bytes in the gap, or -1 for no limit
+}
+
+// Signature is a sequence of Patterns with a Gap between each pair of
+// them
+type Signature struct {
+	Parts []Pattern
+	Gaps  []Gap // Gaps[i] is between Parts[i] and Parts[i+1]
+}
+
+// ParseHexSignature parses a signature written as in YARA's hex
+// strings, for example { 4D 5A ?? 9? [2-4] 50 45 }, in which
+//
+//	4D      matches the byte with that hexadecimal value
+//	??      matches any byte
+//	9? ?D   match any byte with the given high or low nibble
+//	[4]     is a gap of exactly 4 bytes
+//	[2-4]   is a gap of 2 to 4 bytes
+//	[2-]    is a gap of 2 bytes or more
+//	[-]     is a gap of any length
+//
+// The braces and the spaces between bytes are optional. A signature
+// cannot start or end with a gap.
+func ParseHexSignature(s string) (Signature, error) {
+	var sig Signature
+
+	body := strings.TrimSpace(s)
+	if strings.HasPrefix(body, "{") && strings.HasSuffix(body, "}") {
+		body = body[1 : len(body)-1]
+	}
+
+	var part Pattern
+	for i := 0; i < len(body); {
+		switch c := body[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '[':
+			end := strings.IndexByte(body[i:], ']')
+			if end < 0 {
+				return sig, fmt.Errorf("ahocorasick: missing ] in signature %q", s)
+			}
+			if len(part) == 0 {
+				return sig, fmt.Errorf("ahocorasick: misplaced gap in signature %q", s)
+			}
+
+			gap, ok := parseGap(body[i+1 : i+end])
+			if !ok {
+				return sig, fmt.Errorf("ahocorasick: bad gap in signature %q", s)
+			}
+
+			sig.Parts = append(sig.Parts, part)
+			sig.Gaps = append(sig.Gaps, gap)
+			part = nil
+			i += end + 1
+
+		case i+1 < len(body):
+			set, err := parseHexByte(body[i], body[i+1])
+			if err != nil {
+				return sig, fmt.Errorf("ahocorasick: bad byte %q in signature %q", body[i:i+2], s)
+			}
+
+			part = append(part, set)
+			i += 2
+
+		default:
+			return sig, fmt.Errorf("ahocorasick: odd number of digits in signature %q", s)
+		}
+	}
+
+	if len(part) == 0 {
+		return sig, fmt.Errorf("ahocorasick: signature %q is empty or ends with a gap", s)
+	}
+	sig.Parts = append(sig.Parts, part)
+
+	return sig, nil
+}
+
+// MustParseHexSignature is like ParseHexSignature but panics if s
+// cannot be parsed
+func MustParseHexSignature(s string) Signature {
+	sig, err := ParseHexSignature(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return sig
+}
+
+// parseGap parses the inside of a gap such as [2-4] and returns false
+// if it is not valid
+func parseGap(s string) (Gap, bool) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	lo, hi = strings.TrimSpace(lo), strings.TrimSpace(hi)
+
+	g := Gap{0, -1}
+	var err error
+
+	if lo != "" {
+		if g.Min, err = strconv.Atoi(lo); err != nil || g.Min < 0 {
+			return g, false
+		}
+	} else if !isRange {
+		return g, false
+	}
+
+	switch {
+	case !isRange:
+		g.Max = g.Min
+	case hi != "":
+		if g.Max, err = strconv.Atoi(hi); err != nil || g.Max < g.Min {
+			return g, false
+		}
+	}
+
+	return g, true
+}
+
+// parseHexByte returns the set of bytes matched by the hexadecimal
+// digits or wildcards hi and lo
+func parseHexByte(hi, lo byte) (ByteSet, error) {
+	var set ByteSet
+
+	his, err := nibbles(hi)
+	if err != nil {
+		return set, err
+	}
+	los, err := nibbles(lo)
+	if err != nil {
+		return set, err
+	}
+
+	for _, h := range his {
+		for _, l := range los {
+			set.Add(h<<4 | l)
+		}
+	}
+
+	return set, nil
+}
+
+// nibbles returns the values a hexadecimal digit or ? can have
+func nibbles(c byte) ([]byte, error) {
+	if c == '?' {
+		return []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, nil
+	}
+
+	n, err := strconv.ParseUint(string(c), 16, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte{byte(n)}, nil
+}
+
+// offsets is a set of offsets in an input
+type offsets []uint64
+
+// newOffsets creates an empty set for offsets from 0 to n
+func newOffsets(n int) offsets {
+	return make(offsets, n/64+1)
+}
+
+// add adds offset i to the set
+func (o offsets) add(i int) {
+	o[i/64] |= 1 << (i % 64)
+}
+
+// has returns true if offset i is in the set
+func (o offsets) has(i int) bool {
+	return o[i/64]&(1<<(i%64)) != 0
+}
+
+// first returns the smallest offset in the set from lo to hi inclusive,
+// or -1 if there is none
+func (o offsets) first(lo, hi int) int {
+	hi = min(hi, 64*len(o)-1)
+	for lo <= hi {
+		if w := o[lo/64] >> (lo % 64); w != 0 {
+			if i := lo + bits.TrailingZeros64(w); i <= hi {
+				return i
+			}
+			return -1
+		}
+		lo = lo/64*64 + 64
+	}
+
+	return -1
+}
+
+// last returns the largest offset in the set from lo to hi inclusive,
+// or -1 if there is none
+func (o offsets) last(lo, hi int) int {
+	lo = max(lo, 0)
+	for lo <= hi {
+		if w := o[hi/64] << (63 - hi%64); w != 0 {
+			if i := hi - bits.LeadingZeros64(w); i >= lo {
+				return i
+			}
+			return -1
+		}
+		hi = hi/64*64 - 1
+	}
+
+	return -1
+}
+
+// placements records where in an input the parts of a signature on
+// either side of part k, the one its atoms are from, can go
+type placements struct {
+	starts []offsets // starts[j] for j > k holds the offsets at which
+	// part j can start with the parts after it matching
+	ends []offsets // ends[j] for j < k holds the offsets at which part
+	// j can end with the parts before it matching
+}
+
+// place works out the placements of the parts of the signature around
+// part k in `in`. Each part takes one pass over the input, however many
+// gaps there are and however long they can be, so that right() and
+// left() need not try every combination of their lengths.
+func (sig *Signature) place(in []byte, k int) *placements {
+	pl := &placements{
+		starts: make([]offsets, len(sig.Parts)),
+		ends:   make([]offsets, len(sig.Parts)),
+	}
+
+	// Working back from the last part, part j can start at p if it
+	// matches there and part j+1 can start in the gap after it. q is
+	// the first start of part j+1 from lo, which only goes down.
+
+	for j := len(sig.Parts) - 1; j > k; j-- {
+		part := sig.Parts[j]
+		starts := newOffsets(len(in))
+
+		q, scan := -1, len(in)+1
+		for p := len(in) - len(part); p >= 0; p-- {
+			if j < len(sig.Parts)-1 {
+				gap := sig.Gaps[j]
+				for lo := p + len(part) + gap.Min; scan > lo; {
+					if scan--; scan <= len(in) && pl.starts[j+1].has(scan) {
+						q = scan
+					}
+				}
+
+				if q < 0 || gap.Max >= 0 && q > p+len(part)+gap.Max {
+					continue
+				}
+			}
+
+			if part.matches(in, p) {
+				starts.add(p)
+			}
+		}
+
+		pl.starts[j] = starts
+	}
+
+	// Working forward from the first part, part j can end at e if it
+	// matches before e and part j-1 can end in the gap before it. q is
+	// the last end of part j-1 up to hi, which only goes up.
+
+	for j := 0; j < k; j++ {
+		part := sig.Parts[j]
+		ends := newOffsets(len(in))
+
+		q, scan := -1, -1
+		for e := len(part); e <= len(in); e++ {
+			start := e - len(part)
+			if j > 0 {
+				gap := sig.Gaps[j-1]
+				for hi := start - gap.Min; scan < hi; {
+					if scan++; pl.ends[j-1].has(scan) {
+						q = scan
+					}
+				}
+
+				if q < 0 || gap.Max >= 0 && q < start-gap.Max {
+					continue
+				}
+			}
+
+			if part.matches(in, start) {
+				ends.add(e)
+			}
+		}
+
+		pl.ends[j] = ends
+	}
+
+	return pl
+}
+
+// right returns the end of the signature if the parts after part k,
+// which ends at end, match, or -1 if they do not. The shortest gaps are
+// taken, the first one first.
+func (sig *Signature) right(pl *placements, k, end int) int {
+	for j := k + 1; j < len(sig.Parts); j++ {
+		gap := sig.Gaps[j-1]
+
+		hi := 64 * len(pl.starts[j])
+		if gap.Max >= 0 {
+			hi = end + gap.Max
+		}
+
+		p := pl.starts[j].first(end+gap.Min, hi)
+		if p < 0 {
+			return -1
+		}
+		end = p + len(sig.Parts[j])
+	}
+
+	return end
+}
+
+// left returns the start of the signature if the parts before part k,
+// which starts at start, match, or -1 if they do not. The shortest gaps
+// are taken, the last one first.
+func (sig *Signature) left(pl *placements, k, start int) int {
+	for j := k - 1; j >= 0; j-- {
+		gap := sig.Gaps[j]
+
+		lo := 0
+		if gap.Max >= 0 {
+			lo = start - gap.Max
+		}
+
+		e := pl.ends[j].last(lo, start-gap.Min)
+		if e < 0 {
+			return -1
+		}
+		start = e - len(sig.Parts[j])
+	}
+
+	return start
+}
+
+// SignatureMatcher finds the occurrences of a dictionary of Signatures
+type SignatureMatcher struct {
+	m *Matcher // Finds the atoms of the signatures
+
+	signatures []Signature
+
+	owners []int // The signature each atom in m is from
+
+	parts []int // The part of each signature its atoms are from
+
+	offsets []int // The offset in its part of the end of each
+	// signature's atoms
+
+	always []int // The signatures which have no atoms and are
+	// checked at every offset of the input
+}
+
+// NewSignatureMatcher creates a SignatureMatcher for a dictionary of
+// Signatures. The options are passed on to NewMatcher; of them only
+// WithCompact() and WithDFA() make sense here. Signatures with no byte
+// that matches at most 4 bytes, such as { ?? 4? }, are not put into the
+// automaton but checked at every offset of the input, which is slow.
+// The first time the atoms of a signature with gaps are found in an
+// input, a pass is made over it for each part to find where the parts
+// can go, so that gaps of any length are quick to check.
+func NewSignatureMatcher(signatures []Signature, opts ...Option) *SignatureMatcher {
+	sm := &SignatureMatcher{
+		signatures: signatures,
+		parts:      make([]int, len(signatures)),
+		offsets:    make([]int, len(signatures)),
+	}
+
+	var atoms [][]byte
+	for i, sig := range signatures {
+		var best [][]byte
+		for k, p := range sig.Parts {
+			as, end := p.atoms()
+			if len(as) == 0 {
+				continue
+			}
+
+			if best == nil || len(as[0]) > len(best[0]) ||
+				len(as[0]) == len(best[0]) && len(as) < len(best) {
+				best = as
+				sm.parts[i] = k
+				sm.offsets[i] = end
+			}
+		}
+
+		if len(sig.Parts) > 0 && best == nil {
+			sm.always = append(sm.always, i)
+		}
+
+		for _, atom := range best {
+			atoms = append(atoms, atom)
+			sm.owners = append(sm.owners, i)
+		}
+	}
+
+	sm.m = NewMatcher(atoms, opts...)
+
+	return sm
+}
+
+// find calls fn with each occurrence of the signatures in `in`, in no
+// particular order, until fn returns false. For each place where an
+// atom of a signature is found at most one occurrence is reported,
+// which is the one with the shortest gaps.
+func (sm *SignatureMatcher) find(in []byte, fn func(Match) bool) {
+	more := true
+
+	// The placements of the parts of a signature are worked out the
+	// first time one of its atoms is found, and kept for the rest
+
+	var places []*placements
+
+	// check looks for signature i with part k starting at start
+
+	check := func(i, k, start int) {
+		sig := &sm.signatures[i]
+		if !sig.Parts[k].matches(in, start) {
+			return
+		}
+
+		if places == nil {
+			places = make([]*placements, len(sm.signatures))
+		}
+		if places[i] == nil {
+			places[i] = sig.place(in, k)
+		}
+
+		begin := sig.left(places[i], k, start)
+		if begin < 0 {
+			return
+		}
+
+		end := sig.right(places[i], k, start+len(sig.Parts[k]))
+		if end >= 0 {
+			more = fn(Match{i, begin, end})
+		}
+	}
+
+	sm.m.scan(in, func(a, end int) bool {
+		i := sm.owners[a]
+		check(i, sm.parts[i], end-sm.offsets[i])
+		return more
+	})
+
+	for _, i := range sm.always {
+		for start := 0; more && start < len(in); start++ {
+			check(i, 0, start)
+		}
+	}
+}
+
+// FindAll returns every occurrence of the signatures in `in`, in the
+// order in which they end. Occurrences ending at the same offset are
+// ordered longest first. It is thread-safe.
+func (sm *SignatureMatcher) FindAll(in []byte) []Match {
+	var hits []Match
+
+	sm.find(in, func(h Match) bool {
+		hits = append(hits, h)
+		return true
+	})
+
+	sortByEnd(hits)
+
+	return hits
+}
+
+// Match returns the indexes of the signatures which occur in `in`, each
+// once, in the order in which their first occurrences end. It is
+// thread-safe.
+func (sm *SignatureMatcher) Match(in []byte) []int {
+	return firstIndexes(sm.FindAll(in))
+}
+
+// Contains returns true if any of the signatures occur in `in`
+func (sm *SignatureMatcher) Contains(in []byte) bool {
+	found := false
+
+	sm.find(in, func(Match) bool {
+		found = true
+		return false
+	})
+
+	return found
+}
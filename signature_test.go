@@ -0,0 +1,136 @@
+// signature_test.go: test suite for hex signatures
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHexSignature(t *testing.T) {
+	sig := MustParseHexSignature("{ 4D 5A ?? 9? [2-4] 50 45 [3] 00 [1-] 01 [-] 02 }")
+	assert(t, len(sig.Parts) == 5)
+	assert(t, len(sig.Gaps) == 4)
+	assert(t, len(sig.Parts[0]) == 4)
+	assert(t, sig.Parts[0][0].Len() == 1 && sig.Parts[0][0].Contains(0x4d))
+	assert(t, sig.Parts[0][2].Len() == 256)
+	assert(t, sig.Parts[0][3].Len() == 16 && sig.Parts[0][3].Contains(0x9c))
+	assert(t, sig.Gaps[0] == Gap{2, 4})
+	assert(t, sig.Gaps[1] == Gap{3, 3})
+	assert(t, sig.Gaps[2] == Gap{1, -1})
+	assert(t, sig.Gaps[3] == Gap{0, -1})
+
+	sig = MustParseHexSignature("e2?d")
+	assert(t, len(sig.Parts) == 1)
+	assert(t, sig.Parts[0][1].Len() == 16 && sig.Parts[0][1].Contains(0xad))
+
+	for _, s := range []string{"", "{ }", "4D 5", "4D [2] ", "[2] 4D", "4D [2 5A", "4D [4-2] 5A", "4D [x] 5A", "4D 5G", "4D [] 5A"} {
+		_, err := ParseHexSignature(s)
+		assert(t, err != nil)
+	}
+}
+
+func TestSignatureMatcher(t *testing.T) {
+	signatures := []Signature{
+		MustParseHexSignature("{ 4D 5A [2-4] 50 45 }"),
+		MustParseHexSignature("{ 50 45 00 00 }"),
+		MustParseHexSignature("{ ?? [1] ?? FF }"),
+		MustParseHexSignature("{ AA [-] BB CC }"),
+	}
+	in := []byte{0x4d, 0x5a, 0x90, 0x00, 0x03, 0x50, 0x45, 0x00, 0x00, 0xaa, 0x01, 0xbb, 0xcc, 0x4d, 0x5a, 0x50, 0x45}
+
+	for _, opts := range [][]Option{nil, {WithCompact()}, {WithDFA()}} {
+		sm := NewSignatureMatcher(signatures, opts...)
+
+		hits := sm.FindAll(in)
+		assert(t, len(hits) == 3)
+		assert(t, hits[0] == Match{0, 0, 7})
+		assert(t, hits[1] == Match{1, 5, 9})
+		assert(t, hits[2] == Match{3, 9, 13})
+
+		indexes := sm.Match(in)
+		assert(t, len(indexes) == 3)
+		assert(t, indexes[0] == 0)
+
+		assert(t, sm.Contains(in))
+		assert(t, !sm.Contains(in[1:5]))
+		assert(t, sm.Contains([]byte{1, 2, 3, 0xff}))
+	}
+}
+
+func TestSignatureMatcherGaps(t *testing.T) {
+	sm := NewSignatureMatcher([]Signature{MustParseHexSignature("01 [0-2] 02 03 [1-] 04")})
+
+	assert(t, sm.Contains([]byte{1, 2, 3, 9, 4}))
+	assert(t, sm.Contains([]byte{1, 9, 9, 2, 3, 9, 9, 9, 4}))
+	assert(t, !sm.Contains([]byte{1, 9, 9, 9, 2, 3, 9, 4}))
+	assert(t, !sm.Contains([]byte{1, 2, 3, 4}))
+
+	// The first possible end after the atom is taken
+
+	hits := sm.FindAll([]byte{1, 1, 2, 3, 9, 4, 4})
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == Match{0, 1, 6})
+}
+
+// inTime fails the test if fn takes longer than d, rather than waiting
+// for it
+func inTime(t *testing.T, d time.Duration, fn func()) {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatalf("took longer than %v", d)
+	}
+}
+
+func TestSignatureMatcherUnboundedGaps(t *testing.T) {
+	sm := NewSignatureMatcher([]Signature{MustParseHexSignature("{ 41 42 43 [-] 44 [-] 44 [-] 44 [-] 45 }")})
+
+	// Trying every length of each gap takes minutes on these
+
+	in := []byte(strings.Repeat("ABC", 100) + strings.Repeat("D", 300))
+	inTime(t, 5*time.Second, func() {
+		assert(t, !sm.Contains(in))
+		assert(t, !sm.Contains(in[297:]))
+	})
+
+	in = append(in, 'E')
+	inTime(t, 5*time.Second, func() {
+		hits := sm.FindAll(in)
+		assert(t, len(hits) == 100)
+		assert(t, hits[0] == Match{0, 0, len(in)})
+		assert(t, hits[99] == Match{0, 297, len(in)})
+	})
+}
+
+func TestSignatureMatcherNoAtomsUnboundedGaps(t *testing.T) {
+	// No byte of the signature matches 4 bytes or fewer, so it is
+	// checked at every offset
+
+	sm := NewSignatureMatcher([]Signature{
+		MustParseHexSignature("{ ?? [-] 4? [-] 4? [-] 4? [-] 5? }"),
+		MustParseHexSignature("{ 41 [1-] 42 }"),
+	})
+	assert(t, len(sm.always) == 1)
+
+	in := []byte(strings.Repeat("D", 4096))
+	inTime(t, 5*time.Second, func() {
+		assert(t, !sm.Contains(in))
+	})
+
+	in = append(in, 'P')
+	inTime(t, 5*time.Second, func() {
+		hits := sm.FindAll(in)
+		assert(t, len(hits) == len(in)-4)
+		assert(t, hits[0] == Match{0, 0, len(in)})
+	})
+}
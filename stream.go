@@ -0,0 +1,160 @@
+// stream.go: matching against input that arrives in pieces, such as
+// from a socket or a large file, without buffering all of it.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"unicode/utf8"
+)
+
+// Stream matches the dictionary of a Matcher against data written to
+// it in pieces. The state of the trie is carried from one Write to the
+// next so that occurrences spanning Writes are found. Every occurrence
+// is passed to the Stream's callback with offsets relative to the start
+// of the stream.
+//
+// A Stream implements io.WriteCloser so it can be fed with io.Copy. It is
+// not safe for concurrent use, but any number of Streams may share a
+// Matcher.
+type Stream struct {
+	m     *Matcher
+	state uint64 // The state of the automaton reached so far
+
+	offset int // Number of bytes written to the stream
+
+	fn func(Match) // Called for every occurrence found
+
+	// When folding Unicode or matching whole words the input is kept
+	// in buf for as long as it is needed to work out where matches
+	// start and whether they are at word boundaries. base is the
+	// offset of buf[0] in the stream and pos is how far into buf has
+	// been matched.
+
+	buf  []byte
+	base int
+	pos  int
+
+	pending []Match // Occurrences found, with offsets in buf, which
+	// are waiting for the rune after them to be written so that it
+	// can be checked for a word boundary
+}
+
+// NewStream creates a Stream which calls fn for every occurrence of a
+// dictionary entry in the data written to it
+func (m *Matcher) NewStream(fn func(Match)) *Stream {
+	return &Stream{m: m, fn: fn}
+}
+
+// Write matches p against the dictionary, continuing from where the
+// previous Write left off. It always consumes all of p and never
+// returns an error.
+func (s *Stream) Write(p []byte) (int, error) {
+	if s.m.unicode || s.m.isWord != nil {
+		s.writeBuffered(p, true)
+	} else {
+		s.state = s.m.walk(p, s.state, func(i, end int) bool {
+			end += s.offset
+			s.fn(Match{i, end - s.m.lens[i], end})
+			return true
+		})
+	}
+	s.offset += len(p)
+
+	return len(p), nil
+}
+
+// writeBuffered matches p for a Matcher created WithUnicodeFolding()
+// or WithWordBoundaries(). If partial is true a rune cut short at the
+// end of p is kept back until the rest of it is written, and so are
+// occurrences which the rest of the stream could show not to be at a
+// word boundary.
+func (s *Stream) writeBuffered(p []byte, partial bool) {
+	s.buf = append(s.buf, p...)
+
+	found := func(i, end int) bool {
+		s.pending = append(s.pending, Match{i, s.m.start(s.buf, i, end), end})
+		return true
+	}
+
+	if s.m.unicode {
+		s.state, s.pos = s.m.walkFolded(s.buf, s.pos, s.state, partial, found)
+	} else {
+		pos := s.pos
+		s.state = s.m.walk(s.buf[pos:], s.state, func(i, end int) bool {
+			return found(i, pos+end)
+		})
+		s.pos = len(s.buf)
+	}
+
+	// Occurrences are passed on in the order they were found, so one
+	// that has to wait holds up those after it
+
+	n := 0
+	for _, h := range s.pending {
+		if s.m.isWord != nil {
+			if partial && !utf8.FullRune(s.buf[h.End:]) {
+				break
+			}
+			if !s.m.atBoundaries(nil, s.buf, h.Start, h.End) {
+				n++
+				continue
+			}
+		}
+
+		s.fn(Match{h.Pattern, s.base + h.Start, s.base + h.End})
+		n++
+	}
+	s.pending = s.pending[:copy(s.pending, s.pending[n:])]
+
+	// Only the input which could be part of an entry that ends
+	// after s.pos, or of one which is pending, needs to be kept,
+	// along with the rune before it
+
+	keep := foldedStart(s.buf, s.pos, s.m.longest)
+	if len(s.pending) > 0 && s.pending[0].Start < keep {
+		keep = s.pending[0].Start
+	}
+	if s.m.isWord != nil {
+		keep = max(0, keep-utf8.UTFMax)
+	}
+
+	s.buf = s.buf[:copy(s.buf, s.buf[keep:])]
+	s.base += keep
+	s.pos -= keep
+	for i := range s.pending {
+		s.pending[i].Start -= keep
+		s.pending[i].End -= keep
+	}
+}
+
+// Close finishes matching the stream. This is only needed for a
+// Matcher created WithUnicodeFolding(), where the stream may end with
+// part of a rune which was held back in case the rest of it followed,
+// or WithWordBoundaries(), where occurrences at the end of the stream
+// are held back in case a word rune followed. Close always returns
+// nil.
+func (s *Stream) Close() error {
+	if s.m.unicode || s.m.isWord != nil {
+		s.writeBuffered(nil, false)
+	}
+
+	return nil
+}
+
+// Offset returns the number of bytes written to the stream so far
+func (s *Stream) Offset() int {
+	return s.offset
+}
+
+// Reset discards the stream's state so that it can be reused for new
+// data, with offsets starting from zero again
+func (s *Stream) Reset() {
+	s.state = 0
+	s.offset = 0
+	s.buf = s.buf[:0]
+	s.base = 0
+	s.pos = 0
+	s.pending = s.pending[:0]
+}
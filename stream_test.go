@@ -0,0 +1,77 @@
+// stream_test.go: test suite for Stream
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStreamAcrossWrites(t *testing.T) {
+	m := NewStringMatcher([]string{"Superman", "man", "Steel"})
+
+	var hits []Match
+	s := m.NewStream(func(h Match) {
+		hits = append(hits, h)
+	})
+
+	s.Write([]byte("The Man Of St"))
+	s.Write([]byte("eel: Supe"))
+	s.Write([]byte("r"))
+	s.Write([]byte("man"))
+
+	assert(t, s.Offset() == 26)
+	assert(t, len(hits) == 3)
+	assert(t, hits[0] == Match{2, 11, 16})
+	assert(t, hits[1] == Match{0, 18, 26})
+	assert(t, hits[2] == Match{1, 23, 26})
+
+	hits = nil
+	s.Reset()
+	s.Write([]byte("man"))
+	assert(t, s.Offset() == 3)
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == Match{1, 0, 3})
+}
+
+func TestStreamSameAsFindAll(t *testing.T) {
+	m := NewStringMatcher(dictionary6)
+	want := m.FindAll(bytes2)
+
+	for _, size := range []int{1, 2, 7, 64, len(bytes2)} {
+		var hits []Match
+		s := m.NewStream(func(h Match) {
+			hits = append(hits, h)
+		})
+
+		for i := 0; i < len(bytes2); i += size {
+			j := i + size
+			if j > len(bytes2) {
+				j = len(bytes2)
+			}
+			s.Write(bytes2[i:j])
+		}
+
+		assert(t, len(hits) == len(want))
+		for i := range want {
+			assert(t, hits[i] == want[i])
+		}
+	}
+}
+
+func TestStreamCopy(t *testing.T) {
+	m := NewStringMatcher([]string{"Firefox", "Phoenix"})
+
+	count := 0
+	s := m.NewStream(func(Match) {
+		count++
+	})
+
+	n, err := io.Copy(s, strings.NewReader(sbytes2))
+	assert(t, err == nil)
+	assert(t, n == int64(len(bytes2)))
+	assert(t, count == 8)
+}
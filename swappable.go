@@ -0,0 +1,64 @@
+// swappable.go: replacing the Matcher used by many goroutines, such as
+// when a blocklist is reloaded, without stopping them.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"sync/atomic"
+)
+
+// SwappableMatcher holds a Matcher which can be replaced at any time
+// while other goroutines are matching with it. Its methods are safe for
+// concurrent use. Each call uses the Matcher which was installed when
+// it started, so a call that overlaps a Store gets the results of
+// either the old Matcher or the new one, never a mixture.
+type SwappableMatcher struct {
+	m atomic.Pointer[Matcher]
+}
+
+// NewSwappableMatcher creates a SwappableMatcher which starts out using
+// m
+func NewSwappableMatcher(m *Matcher) *SwappableMatcher {
+	s := new(SwappableMatcher)
+	s.m.Store(m)
+
+	return s
+}
+
+// Load returns the Matcher in use
+func (s *SwappableMatcher) Load() *Matcher {
+	return s.m.Load()
+}
+
+// Store installs m, which is used by every call from then on
+func (s *SwappableMatcher) Store(m *Matcher) {
+	s.m.Store(m)
+}
+
+// Swap installs m and returns the Matcher it replaces
+func (s *SwappableMatcher) Swap(m *Matcher) *Matcher {
+	return s.m.Swap(m)
+}
+
+// MatchThreadSafe calls MatchThreadSafe() on the Matcher in use
+func (s *SwappableMatcher) MatchThreadSafe(in []byte) []int {
+	return s.m.Load().MatchThreadSafe(in)
+}
+
+// Contains calls Contains() on the Matcher in use
+func (s *SwappableMatcher) Contains(in []byte) bool {
+	return s.m.Load().Contains(in)
+}
+
+// FindAll calls FindAll() on the Matcher in use
+func (s *SwappableMatcher) FindAll(in []byte) []Match {
+	return s.m.Load().FindAll(in)
+}
+
+// FindAllNonOverlapping calls FindAllNonOverlapping() on the Matcher in
+// use
+func (s *SwappableMatcher) FindAllNonOverlapping(in []byte) []Match {
+	return s.m.Load().FindAllNonOverlapping(in)
+}
@@ -0,0 +1,54 @@
+// swappable_test.go: test suite for SwappableMatcher
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSwappableMatcher(t *testing.T) {
+	m1 := NewStringMatcher([]string{"Mozilla", "Firefox"})
+	m2 := NewStringMatcher([]string{"Phoenix"}, WithDFA())
+
+	s := NewSwappableMatcher(m1)
+	assert(t, s.Load() == m1)
+	assert(t, len(s.MatchThreadSafe(bytes2)) == 2)
+
+	assert(t, s.Swap(m2) == m1)
+	assert(t, s.Load() == m2)
+	assert(t, len(s.MatchThreadSafe(bytes2)) == 1)
+	assert(t, len(s.FindAll(bytes2)) == 2)
+	assert(t, len(s.FindAllNonOverlapping(bytes2)) == 2)
+	assert(t, !s.Contains([]byte("Mozilla")))
+}
+
+func TestSwappableMatcherConcurrently(t *testing.T) {
+	matchers := []*Matcher{
+		NewStringMatcher([]string{"Mozilla", "Firefox"}),
+		NewStringMatcher([]string{"Phoenix", "Gecko"}, WithCompact()),
+	}
+	s := NewSwappableMatcher(matchers[0])
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				hits := s.MatchThreadSafe(bytes2)
+				assert(t, len(hits) == 2)
+				assert(t, s.Contains(bytes2))
+			}
+		}()
+	}
+
+	for j := 0; j < 100; j++ {
+		s.Store(matchers[j%2])
+	}
+
+	wg.Wait()
+}
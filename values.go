@@ -0,0 +1,76 @@
+// values.go: attaching a value to each dictionary entry, such as a rule
+// ID or a replacement, so that callers do not need to keep arrays in
+// step with the dictionary.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+// ValueMatcher is a Matcher with a value of type T for each dictionary
+// entry. All the methods of Matcher can be used on it; the indexes they
+// return can be turned into values with Value().
+type ValueMatcher[T any] struct {
+	*Matcher
+
+	values []T // The value of each dictionary entry
+}
+
+// ValueMatch is an occurrence of a dictionary entry along with the
+// entry's value
+type ValueMatch[T any] struct {
+	Match
+	Value T
+}
+
+// NewValueMatcher creates a ValueMatcher for a dictionary in which
+// entry i has the value values[i]. It panics if there is not a value
+// for every entry.
+func NewValueMatcher[T any](dictionary [][]byte, values []T, opts ...Option) *ValueMatcher[T] {
+	if len(values) != len(dictionary) {
+		panic("ahocorasick: dictionary and values differ in length")
+	}
+
+	return &ValueMatcher[T]{NewMatcher(dictionary, opts...), values}
+}
+
+// NewStringValueMatcher creates a ValueMatcher for a dictionary of
+// strings (this is a helper to make initialization easy)
+func NewStringValueMatcher[T any](dictionary []string, values []T, opts ...Option) *ValueMatcher[T] {
+	var d [][]byte
+	for _, s := range dictionary {
+		d = append(d, []byte(s))
+	}
+
+	return NewValueMatcher(d, values, opts...)
+}
+
+// Value returns the value of the dictionary entry with index i
+func (m *ValueMatcher[T]) Value(i int) T {
+	return m.values[i]
+}
+
+// FindAllValues returns every occurrence found as FindAll() does, with
+// the value of each entry found
+func (m *ValueMatcher[T]) FindAllValues(in []byte) []ValueMatch[T] {
+	return m.withValues(m.FindAll(in))
+}
+
+// FindAllNonOverlappingValues returns the occurrences chosen by
+// FindAllNonOverlapping(), with the value of each entry found
+func (m *ValueMatcher[T]) FindAllNonOverlappingValues(in []byte) []ValueMatch[T] {
+	return m.withValues(m.FindAllNonOverlapping(in))
+}
+
+// withValues pairs each occurrence in hits with its entry's value
+func (m *ValueMatcher[T]) withValues(hits []Match) []ValueMatch[T] {
+	if hits == nil {
+		return nil
+	}
+
+	vhits := make([]ValueMatch[T], len(hits))
+	for i, h := range hits {
+		vhits[i] = ValueMatch[T]{h, m.values[h.Pattern]}
+	}
+
+	return vhits
+}
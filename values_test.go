@@ -0,0 +1,44 @@
+// values_test.go: test suite for ValueMatcher
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"testing"
+)
+
+func TestValueMatcher(t *testing.T) {
+	type rule struct {
+		id       int
+		severity string
+	}
+
+	m := NewStringValueMatcher([]string{"Mozilla", "Firefox", "Phoenix"},
+		[]rule{{101, "low"}, {102, "high"}, {103, "low"}}, WithMatchKind(LeftmostLongest))
+
+	hits := m.FindAllValues([]byte("Firefox, formerly Phoenix"))
+	assert(t, len(hits) == 2)
+	assert(t, hits[0].Match == Match{1, 0, 7})
+	assert(t, hits[0].Value == rule{102, "high"})
+	assert(t, hits[1].Match == Match{2, 18, 25})
+	assert(t, hits[1].Value.id == 103)
+
+	hits = m.FindAllNonOverlappingValues([]byte("Mozilla"))
+	assert(t, len(hits) == 1)
+	assert(t, hits[0].Value.severity == "low")
+
+	assert(t, m.FindAllValues([]byte("Gecko")) == nil)
+
+	indexes := m.Match(bytes2)
+	assert(t, len(indexes) == 3)
+	assert(t, m.Value(indexes[0]).id == 102)
+}
+
+func TestValueMatcherLengths(t *testing.T) {
+	defer func() {
+		assert(t, recover() != nil)
+	}()
+
+	NewStringValueMatcher([]string{"a", "b"}, []int{1})
+}
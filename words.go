@@ -0,0 +1,55 @@
+// words.go: only finding dictionary entries which are whole words, for
+// keyword detection, rather than anywhere in the input.
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"unicode/utf8"
+)
+
+// WithWordBoundaries makes the Matcher only find occurrences of
+// dictionary entries which are not preceded or followed by a word rune,
+// so that "cat" is found in "a cat." but not in "concatenate". isWord
+// decides which runes are word runes: IsASCIIWord gives the same words
+// as \w in Go's regexp package and unicode.IsLetter treats letters in
+// any script as word runes. Bytes which are not valid UTF-8 are passed
+// to isWord as utf8.RuneError.
+//
+// Occurrences are checked once they have been found, so matching costs
+// more only when occurrences are found. A function cannot be
+// serialized, so MarshalBinary returns an error for such a Matcher.
+func WithWordBoundaries(isWord func(r rune) bool) Option {
+	return func(m *Matcher) {
+		m.isWord = isWord
+	}
+}
+
+// IsASCIIWord returns true if r is an ASCII letter, digit or
+// underscore. It can be given to WithWordBoundaries().
+func IsASCIIWord(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_'
+}
+
+// atBoundaries returns true if the occurrence in[start:end] is at word
+// boundaries. before is the input which came before `in`, if any.
+func (m *Matcher) atBoundaries(before, in []byte, start, end int) bool {
+	if start > 0 {
+		before = in[:start]
+	}
+
+	if len(before) > 0 {
+		if r, _ := utf8.DecodeLastRune(before); m.isWord(r) {
+			return false
+		}
+	}
+
+	if end < len(in) {
+		if r, _ := utf8.DecodeRune(in[end:]); m.isWord(r) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,93 @@
+// words_test.go: test suite for matching whole words
+//
+// Copyright (c) 2013 CloudFlare, Inc.
+
+package ahocorasick
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestWordBoundaries(t *testing.T) {
+	m := NewStringMatcher([]string{"cat", "cats", "at"}, WithWordBoundaries(IsASCIIWord))
+
+	hits := m.FindAll([]byte("cat concatenate cats, at_ cat"))
+	assert(t, len(hits) == 3)
+	assert(t, hits[0] == Match{0, 0, 3})
+	assert(t, hits[1] == Match{1, 16, 20})
+	assert(t, hits[2] == Match{0, 26, 29})
+
+	assert(t, m.Contains([]byte("(at)")))
+	assert(t, !m.Contains([]byte("scatter")))
+
+	indexes := m.Match([]byte("cats at"))
+	assert(t, len(indexes) == 2)
+	assert(t, indexes[0] == 1)
+	assert(t, indexes[1] == 2)
+}
+
+func TestWordBoundariesUnicode(t *testing.T) {
+	in := []byte("écat café")
+
+	m := NewStringMatcher([]string{"cat", "caf"}, WithWordBoundaries(IsASCIIWord))
+	hits := m.FindAll(in)
+	assert(t, len(hits) == 2)
+	assert(t, hits[0] == Match{0, 2, 5})
+	assert(t, hits[1] == Match{1, 6, 9})
+
+	m = NewStringMatcher([]string{"cat", "caf", "CAFÉ"}, WithWordBoundaries(unicode.IsLetter), WithUnicodeFolding())
+	hits = m.FindAll(in)
+	assert(t, len(hits) == 1)
+	assert(t, hits[0] == Match{2, 6, 11})
+}
+
+func TestWordBoundariesStream(t *testing.T) {
+	in := []byte(strings.Repeat("the cat sat on the mat, théâtre at the catalogue cat. ", 3))
+
+	for _, opts := range [][]Option{
+		{WithWordBoundaries(IsASCIIWord)},
+		{WithWordBoundaries(unicode.IsLetter), WithUnicodeFolding()},
+		{WithWordBoundaries(unicode.IsLetter), WithDFA()},
+	} {
+		m := NewStringMatcher([]string{"cat", "the", "at", "THÉÂTRE", "t"}, opts...)
+		want := m.FindAll(in)
+
+		for _, size := range []int{1, 2, 3, 7, len(in)} {
+			var hits []Match
+			s := m.NewStream(func(h Match) {
+				hits = append(hits, h)
+			})
+			for i := 0; i < len(in); i += size {
+				s.Write(in[i:min(i+size, len(in))])
+			}
+			s.Close()
+
+			assert(t, len(hits) == len(want))
+			for i := range want {
+				assert(t, hits[i] == want[i])
+			}
+		}
+	}
+}
+
+func TestWordBoundariesReplacer(t *testing.T) {
+	in := []byte(strings.Repeat("the cat sat on the mat, théâtre at the catalogue cat. ", 3))
+	m := NewStringMatcher([]string{"cat", "the", "at", "t"}, WithWordBoundaries(unicode.IsLetter), WithMatchKind(LeftmostLongest))
+	replacements := [][]byte{[]byte("dog"), []byte("a"), []byte("@"), []byte("T")}
+
+	want := string(m.Replace(in, replacements))
+	assert(t, strings.HasPrefix(want, "a dog sat on a mat, théâtre @ a catalogue dog"))
+
+	for _, size := range []int{1, 2, 5, len(in)} {
+		var out strings.Builder
+		r := m.NewReplacer(&out, replacements)
+		for i := 0; i < len(in); i += size {
+			r.Write(in[i:min(i+size, len(in))])
+		}
+		r.Close()
+
+		assert(t, out.String() == want)
+	}
+}